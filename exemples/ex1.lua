@@ -1,5 +1,12 @@
 print("hello from lua")
 local aws = require("aws")
+local cidr = require("cidr")
+
+-- the first two bytes of the network, e.g. --var network=10.1 for another environment.
+vars({
+    network = { default = "10.0" },
+})
+local vpc_cidr = vars.network .. ".0.0/16"
 
 local function print_table(t)
     if t == nil then
@@ -21,17 +28,17 @@ if err ~= nil then
     os.exit(1)
 end
 print("AZ in region:")
-for k, v in pairs(ret.AvailabilityZones) do
-    print("ZoneID:" .. v.ZoneName)
+for _, v in ipairs(ret) do
+    print("ZoneID:" .. v.id .. " ZoneName:" .. v.zone_name)
 end
 
 -- Look for a VPC with the tag myvpc=true.
--- If not found create one with cidr=10.0.0.0/16
+-- If not found create one with cidr=<network>.0.0/16
 
 -- create vpc
-local function create_vpc(cidr, tags)
+local function create_vpc(cidr_block, tags)
     vpc = {
-        cidr = cidr,
+        cidr = cidr_block,
     }
     if tags ~= nil and type(tags) == "table" then
         vpc["tags"] = tags
@@ -39,25 +46,17 @@ local function create_vpc(cidr, tags)
     return aws.create("aws_vpc", vpc)
 end
 
--- Get VPCs
-local vpcs, err = aws.list("aws_vpc", {})
+-- Find the VPCs with the tag myvpc=true
+local vpcs, err = aws.find_by_tag({ myvpc = "true" }, { types = { "aws_vpc" } })
 if err ~= nil then
     print(err)
     os.exit(1)
 end
 
 local vpc = { found = false }
-if vpcs ~= nil then
-    for _, _vpc in pairs(vpcs.Vpcs) do
-        if _vpc.Tags ~= nil then
-            for _, v in pairs(_vpc.Tags) do
-                if v.Key == "myvpc" and v.Value == "true" then
-                    vpc.found = true
-                    vpc.id = _vpc.VpcId
-                end
-            end
-        end
-    end
+for _, _vpc in ipairs(vpcs) do
+    vpc.found = true
+    vpc.id = _vpc.id
 end
 
 local vpc_id
@@ -65,14 +64,14 @@ if vpc.found then
     print("myvpc VPC found. ID: " .. vpc.id)
     vpc_id = vpc.id
 else
-    local vpc, err = create_vpc("10.0.0.0/16", { myvpc = "true" })
+    local vpc, err = create_vpc(vpc_cidr, { myvpc = "true" })
     if err ~= nil then
         print("vpc creation failed: " .. err)
         os.exit(1)
     end
     if vpc ~= nil then
-        print("vpc created: " .. vpc.Vpc.VpcId)
-        vpc_id = vpc.Vpc.VpcId
+        print("vpc created: " .. vpc.id)
+        vpc_id = vpc.id
     end
 end
 
@@ -90,9 +89,9 @@ local ss, err = aws.list("aws_subnet", {
 )
 
 local subnets = {}
-function subnets:find(cidr)
+function subnets:find(cidr_block)
     for _, v in ipairs(self) do
-        if v.cidr == cidr then return true end
+        if v.cidr == cidr_block then return true end
     end
     return false
 end
@@ -100,46 +99,48 @@ end
 if err ~= nil then
     print("listing subnets failed: " .. err)
 else
-    if ss.Subnets == nil then
+    if #ss == 0 then
         print("no subnets found for vpc " .. vpc_id)
     else
-        for k, v in pairs(ss.Subnets) do
-            print(string.format("found subnet cidr=%s, id=%s", v.CidrBlock, v.SubnetId))
-            table.insert(subnets, { id = v.SubnetId, cidr = v.CidrBlock })
+        for _, v in ipairs(ss) do
+            print(string.format("found subnet cidr=%s, id=%s", v.cidr_block, v.id))
+            table.insert(subnets, { id = v.id, cidr = v.cidr_block })
         end
     end
 end
 
 print("create one private and one public subnet in each az...")
-print("private subnets cidr starts at 10.0.1.0/24")
-print("public subnets cidr starts at 10.0.100.0/24")
+print("private subnets cidr starts at " .. cidr.subnet(vpc_cidr, 8, 1))
+print("public subnets cidr starts at " .. cidr.subnet(vpc_cidr, 8, 101))
 
 local tags = {
     myvpc = "true"
 }
 
 local i = 1
-for _, az in pairs(ret.AvailabilityZones) do
+for _, az in ipairs(ret) do
     -- for each az create one subnet
     for _, j in ipairs({ i, i + 100 }) do
-        local next_cidr = "10.0." .. j .. ".0/24"
+        local next_cidr = cidr.subnet(vpc_cidr, 8, j)
         if not subnets:find(next_cidr) then
             local s, err = aws.create("aws_subnet",{
                 vpc_id = vpc_id,
                 cidr = next_cidr,
-                availability_zone_id = az.ZoneID,
+                availability_zone_id = az.id,
                 tags = tags,
             })
             if err ~= nil then
                 print("error creating subnet in az " .. err)
             else
-                print(string.format("subnet %s created in az %s", next_cidr, az.ZoneID))
+                print(string.format("subnet %s created in az %s", next_cidr, az.id))
             end
         end
         i = i + 1
     end
 end
 
+aws.output("vpc_id", vpc_id)
+
 -------------------
 ---
 --- NATS
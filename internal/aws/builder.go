@@ -2,17 +2,17 @@ package aws
 
 import (
 	"context"
-	"errors"
+	"fmt"
 
 	"github.com/tupyy/aws-lua/internal/lua"
 )
 
 type clientBuilder[T, S any] struct {
-	config              ClientConfiguration
-	client              ClientType
-	opType              OpType
-	tranformInputFunc   func(o lua.Object) T
-	transformOutputFunc func(s S) lua.Object
+	config                  ClientConfiguration
+	op                      func(config ClientConfiguration) opFunc
+	tranformInputFunc       func(o lua.Object) (T, error)
+	transformOutputFunc     func(s S) lua.Object
+	transformListOutputFunc func(s S) []lua.Object
 }
 
 func NewBuilder[T, S any](c ClientConfiguration) *clientBuilder[T, S] {
@@ -21,17 +21,13 @@ func NewBuilder[T, S any](c ClientConfiguration) *clientBuilder[T, S] {
 	}
 }
 
-func (b *clientBuilder[T, S]) Type(t ClientType) *clientBuilder[T, S] {
-	b.client = t
+// Op sets the factory of the op func. The op func must take a T and return a S.
+func (b *clientBuilder[T, S]) Op(op func(config ClientConfiguration) opFunc) *clientBuilder[T, S] {
+	b.op = op
 	return b
 }
 
-func (b *clientBuilder[T, S]) Op(opType OpType) *clientBuilder[T, S] {
-	b.opType = opType
-	return b
-}
-
-func (b *clientBuilder[T, S]) TransformInputFunc(f func(o lua.Object) T) *clientBuilder[T, S] {
+func (b *clientBuilder[T, S]) TransformInputFunc(f func(o lua.Object) (T, error)) *clientBuilder[T, S] {
 	b.tranformInputFunc = f
 	return b
 }
@@ -41,26 +37,41 @@ func (b *clientBuilder[T, S]) TransformOutputFunc(f func(s S) lua.Object) *clien
 	return b
 }
 
-func (b *clientBuilder[T, S]) Build(ctx context.Context) func(ctx context.Context, o lua.Object) (lua.Object, error) {
+func (b *clientBuilder[T, S]) TransformListOutputFunc(f func(s S) []lua.Object) *clientBuilder[T, S] {
+	b.transformListOutputFunc = f
+	return b
+}
+
+func (b *clientBuilder[T, S]) Build() resourceHandler {
 	return func(ctx context.Context, o lua.Object) (lua.Object, error) {
-		opFunc := b.getOpFunc()
-		output, err := opFunc(ctx, b.tranformInputFunc(o))
+		output, err := b.run(ctx, o)
 		if err != nil {
 			return lua.Object{}, err
 		}
-		return b.transformOutputFunc(output.(S)), nil
+		return b.transformOutputFunc(output), nil
 	}
 }
 
-func (b *clientBuilder[T, S]) getOpFunc() opFunc {
-	switch b.client {
-	case IamClient:
-		return iamGetOpFunc(b.opType, b.config)
-	case Ec2Client:
-		return ec2GetOpFunc(b.opType, b.config)
+// BuildList builds a handler returning a list of resources transformed by the list output func.
+func (b *clientBuilder[T, S]) BuildList() listHandler {
+	return func(ctx context.Context, o lua.Object) ([]lua.Object, error) {
+		output, err := b.run(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		return b.transformListOutputFunc(output), nil
 	}
+}
 
-	return func(ctx context.Context, input interface{}) (interface{}, error) {
-		return nil, errors.New("unknown client or op type")
+func (b *clientBuilder[T, S]) run(ctx context.Context, o lua.Object) (S, error) {
+	var s S
+	input, err := b.tranformInputFunc(o)
+	if err != nil {
+		return s, fmt.Errorf("invalid input: %w", err)
+	}
+	output, err := b.op(b.config)(ctx, input)
+	if err != nil {
+		return s, err
 	}
+	return output.(S), nil
 }
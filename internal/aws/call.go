@@ -0,0 +1,70 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Call calls any operation of the ec2 or iam client by its name, e.g. Call(ctx, "ec2", "DescribeRouteTables", o).
+// The input is decoded from the object and the whole output is returned.
+func (a *AwsProvider) Call(ctx context.Context, service, operation string, o lua.Object) (lua.Object, error) {
+	var (
+		client interface{}
+		err    error
+	)
+
+	switch strings.ToLower(service) {
+	case "ec2":
+		client, err = createEc2Client(ctx, a.config)
+	case "iam":
+		client, err = createIamClient(ctx, a.config)
+	default:
+		return lua.Object{}, fmt.Errorf("unknown service %q", service)
+	}
+	if err != nil {
+		return lua.Object{}, err
+	}
+
+	return callMethod(ctx, client, operation, o)
+}
+
+// callMethod calls the method named operation on client. The method must have the signature of a sdk operation:
+// func(ctx context.Context, params *Input, optFns ...func(*Options)) (*Output, error)
+func callMethod(ctx context.Context, client interface{}, operation string, o lua.Object) (lua.Object, error) {
+	method := reflect.ValueOf(client).MethodByName(operation)
+	if !method.IsValid() || !isOperation(method.Type()) {
+		return lua.Object{}, fmt.Errorf("unknown operation %q", operation)
+	}
+
+	input := reflect.New(method.Type().In(1).Elem())
+	if err := (decoder{}).decode(o, input.Interface()); err != nil {
+		return lua.Object{}, fmt.Errorf("invalid input: %w", err)
+	}
+
+	results := method.Call([]reflect.Value{reflect.ValueOf(ctx), input})
+	if err, _ := results[1].Interface().(error); err != nil {
+		return lua.Object{}, err
+	}
+
+	return toLua(results[0].Interface()), nil
+}
+
+func isOperation(t reflect.Type) bool {
+	if t.NumIn() < 2 || t.NumOut() != 2 {
+		return false
+	}
+	if t.In(0) != contextType || t.Out(1) != errorType {
+		return false
+	}
+	in := t.In(1)
+	return in.Kind() == reflect.Pointer && in.Elem().Kind() == reflect.Struct
+}
@@ -0,0 +1,60 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+type describeThingsInput struct {
+	ThingIds []string
+}
+
+type describeThingsOutput struct {
+	Things []b
+}
+
+type fakeClientOptions struct{}
+
+type fakeClient struct{}
+
+func (f *fakeClient) DescribeThings(ctx context.Context, params *describeThingsInput, optFns ...func(*fakeClientOptions)) (*describeThingsOutput, error) {
+	things := make([]b, 0, len(params.ThingIds))
+	for _, id := range params.ThingIds {
+		things = append(things, b{BFoo: id})
+	}
+	return &describeThingsOutput{Things: things}, nil
+}
+
+func (f *fakeClient) DeleteThing(ctx context.Context, params *describeThingsInput, optFns ...func(*fakeClientOptions)) (*describeThingsOutput, error) {
+	return nil, errors.New("dependency violation")
+}
+
+func (f *fakeClient) Options() fakeClientOptions {
+	return fakeClientOptions{}
+}
+
+func TestCallMethod(t *testing.T) {
+	RegisterTestingT(t)
+
+	o, err := callMethod(context.TODO(), &fakeClient{}, "DescribeThings", lua.Object{"thing_ids": []interface{}{"1", "2"}})
+	Expect(err).To(BeNil())
+	Expect(o).To(Equal(lua.Object{
+		"Things": []interface{}{lua.Object{"BFoo": "1"}, lua.Object{"BFoo": "2"}},
+	}))
+
+	_, err = callMethod(context.TODO(), &fakeClient{}, "DeleteThing", lua.Object{})
+	Expect(err).To(MatchError("dependency violation"))
+
+	_, err = callMethod(context.TODO(), &fakeClient{}, "DescribeThings", lua.Object{"unknown": "1"})
+	Expect(err).To(MatchError(`invalid input: unknown key "unknown"`))
+
+	_, err = callMethod(context.TODO(), &fakeClient{}, "Options", lua.Object{})
+	Expect(err).To(MatchError(`unknown operation "Options"`))
+
+	_, err = callMethod(context.TODO(), &fakeClient{}, "Missing", lua.Object{})
+	Expect(err).To(MatchError(`unknown operation "Missing"`))
+}
@@ -2,14 +2,101 @@ package aws
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 )
 
+// services are the services of the clients, by the name used to override their endpoint.
+var services = map[string]bool{"ec2": true, "iam": true}
+
 type ClientConfiguration struct {
 	AccessKey string
 	SecretKey string
 	Region    string
+	// HTTPClient sends the requests of the clients. The default client of the sdk is used when nil.
+	HTTPClient aws.HTTPClient
+	// EndpointURL replaces the endpoints of all the services, e.g. to use a local emulator.
+	EndpointURL string
+	// Endpoints replaces the endpoint of a service ("ec2" or "iam"). It takes precedence over EndpointURL.
+	Endpoints map[string]string
+	// InsecureSkipVerify disables the verification of the TLS certificates. It is meant for local emulators.
+	InsecureSkipVerify bool
+}
+
+// Validate checks the endpoints of the configuration.
+func (c ClientConfiguration) Validate() error {
+	names := make([]string, 0, len(c.Endpoints))
+	for name := range c.Endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !services[name] {
+			return fmt.Errorf("unknown service %q, expected ec2 or iam", name)
+		}
+		if err := validateEndpoint(c.Endpoints[name]); err != nil {
+			return fmt.Errorf("endpoint of %s: %w", name, err)
+		}
+	}
+	if c.EndpointURL != "" {
+		if err := validateEndpoint(c.EndpointURL); err != nil {
+			return fmt.Errorf("endpoint url: %w", err)
+		}
+	}
+	return nil
+}
+
+// endpoint returns the endpoint of the service or an empty string to use the endpoint of aws.
+func (c ClientConfiguration) endpoint(service string) string {
+	if e, found := c.Endpoints[service]; found {
+		return e
+	}
+	return c.EndpointURL
+}
+
+func validateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) url", endpoint)
+	}
+	return nil
+}
+
+// load returns the aws config of the clients.
+func (c ClientConfiguration) load(ctx context.Context) (aws.Config, error) {
+	optFn := func(opts *awsConfig.LoadOptions) error {
+		opts.Region = c.Region
+		opts.Credentials = getAwsCredentials(ctx, c.AccessKey, c.SecretKey)
+		if c.InsecureSkipVerify && c.HTTPClient == nil {
+			opts.HTTPClient = awshttp.NewBuildableClient().WithTransportOptions(func(t *http.Transport) {
+				if t.TLSClientConfig == nil {
+					t.TLSClientConfig = &tls.Config{}
+				}
+				t.TLSClientConfig.InsecureSkipVerify = true
+			})
+		}
+		return nil
+	}
+	config, err := awsConfig.LoadDefaultConfig(ctx, optFn)
+	if err != nil {
+		return config, fmt.Errorf("failed to create aws config for account: %w", err)
+	}
+	// the client is set after loading because the sdk can only customize its own clients (e.g. with AWS_CA_BUNDLE).
+	if c.HTTPClient != nil {
+		config.HTTPClient = c.HTTPClient
+	}
+	config.APIOptions = append(config.APIOptions, addTracing)
+	return config, nil
 }
 
 // getAwsCredentials returns a CredentialsProviderFunc to be used to create aws config.
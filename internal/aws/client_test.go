@@ -0,0 +1,160 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/logger"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestHTTPClient(t *testing.T) {
+	RegisterTestingT(t)
+
+	var action string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		action = strings.SplitN(string(body), "&", 2)[0]
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/xml"}},
+			Body: io.NopCloser(strings.NewReader(`<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+<vpcSet><item><vpcId>vpc-1</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state></item></vpcSet>
+</DescribeVpcsResponse>`)),
+			Request: req,
+		}, nil
+	})}
+
+	p := New(ClientConfiguration{AccessKey: "a", SecretKey: "s", Region: "eu-west-1", HTTPClient: client})
+	vpcs, err := p.List(context.TODO(), "aws_vpc", lua.Object{})
+	Expect(err).To(BeNil())
+	Expect(action).To(Equal("Action=DescribeVpcs"))
+	Expect(vpcs).To(HaveLen(1))
+	Expect(vpcs[0]["id"]).To(Equal("vpc-1"))
+	Expect(vpcs[0]["cidr_block"]).To(Equal("10.0.0.0/16"))
+}
+
+func TestEndpoints(t *testing.T) {
+	RegisterTestingT(t)
+
+	hosts := []string{}
+	emulator := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/xml")
+		if strings.Contains(string(body), "Action=ListUsers") {
+			io.WriteString(w, `<ListUsersResponse><ListUsersResult><IsTruncated>false</IsTruncated><Users>
+<member><UserName>bob</UserName><Arn>arn:aws:iam::000000000000:user/bob</Arn></member>
+</Users></ListUsersResult></ListUsersResponse>`)
+			return
+		}
+		io.WriteString(w, `<DescribeVpcsResponse><vpcSet/></DescribeVpcsResponse>`)
+	})
+	tlsServer := httptest.NewTLSServer(emulator)
+	defer tlsServer.Close()
+	server := httptest.NewServer(emulator)
+	defer server.Close()
+
+	c := ClientConfiguration{
+		AccessKey:          "test",
+		SecretKey:          "test",
+		Region:             "us-east-1",
+		EndpointURL:        tlsServer.URL,
+		Endpoints:          map[string]string{"iam": server.URL},
+		InsecureSkipVerify: true,
+	}
+	Expect(c.Validate()).To(Succeed())
+	p := New(c)
+
+	vpcs, err := p.List(context.TODO(), "aws_vpc", lua.Object{})
+	Expect(err).To(BeNil())
+	Expect(vpcs).To(BeEmpty())
+
+	users, err := p.List(context.TODO(), "aws_user", lua.Object{})
+	Expect(err).To(BeNil())
+	Expect(users).To(HaveLen(1))
+	Expect(users[0]["id"]).To(Equal("bob"))
+
+	Expect(hosts).To(Equal([]string{strings.TrimPrefix(tlsServer.URL, "https://"), strings.TrimPrefix(server.URL, "http://")}))
+
+	// the certificate of the emulator is rejected unless the verification is disabled.
+	c.InsecureSkipVerify = false
+	_, err = New(c).List(context.TODO(), "aws_vpc", lua.Object{})
+	Expect(err).ToNot(BeNil())
+
+	Expect(ClientConfiguration{Endpoints: map[string]string{"s3": server.URL}}.Validate()).ToNot(Succeed())
+	Expect(ClientConfiguration{EndpointURL: "localhost:4566"}.Validate()).ToNot(Succeed())
+}
+
+func TestTrace(t *testing.T) {
+	RegisterTestingT(t)
+
+	var logs bytes.Buffer
+	log, err := logger.New(&logs, logger.LevelDebug, "json")
+	Expect(err).To(BeNil())
+	defer logger.SetDefault(logger.Default())
+	logger.SetDefault(log)
+
+	attempts := 0
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/xml"}, "X-Amzn-Requestid": {"req-1"}},
+			Body: io.NopCloser(strings.NewReader(`<CreateAccessKeyResponse><CreateAccessKeyResult><AccessKey>
+<UserName>bob</UserName><AccessKeyId>AKIAEXAMPLE</AccessKeyId><SecretAccessKey>s3cr3t</SecretAccessKey><Status>Active</Status>
+</AccessKey></CreateAccessKeyResult></CreateAccessKeyResponse>`)),
+			Request: req,
+		}, nil
+	})}
+
+	p := New(ClientConfiguration{AccessKey: "AKIACONFIG", SecretKey: "configsecret", Region: "us-east-1", HTTPClient: client})
+	_, err = p.Create(context.TODO(), "aws_access_key", lua.Object{"user_name": "bob"})
+	Expect(err).To(BeNil())
+
+	Expect(logs.String()).ToNot(ContainSubstring("s3cr3t"))
+	Expect(logs.String()).ToNot(ContainSubstring("AKIAEXAMPLE"))
+	Expect(logs.String()).ToNot(ContainSubstring("configsecret"))
+
+	var trace map[string]interface{}
+	Expect(json.Unmarshal(logs.Bytes(), &trace)).To(Succeed())
+	Expect(trace["msg"]).To(Equal("aws call"))
+	Expect(trace["service"]).To(Equal("IAM"))
+	Expect(trace["operation"]).To(Equal("CreateAccessKey"))
+	Expect(trace["request_id"]).To(Equal("req-1"))
+	Expect(trace["retries"]).To(Equal(1.0))
+	Expect(trace["input"]).To(HaveKeyWithValue("UserName", "bob"))
+	Expect(trace["output"]).To(HaveKeyWithValue("AccessKey", "REDACTED"))
+}
+
+func TestCreateUserError(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusConflict,
+			Header:     http.Header{"Content-Type": {"text/xml"}},
+			Body: io.NopCloser(strings.NewReader(`<ErrorResponse><Error><Type>Sender</Type><Code>EntityAlreadyExists</Code>
+<Message>User with name bob already exists.</Message></Error></ErrorResponse>`)),
+			Request: req,
+		}, nil
+	})}
+
+	p := New(ClientConfiguration{AccessKey: "a", SecretKey: "s", Region: "us-east-1", HTTPClient: client})
+	_, err := p.Create(context.TODO(), "aws_user", lua.Object{"user_name": "bob"})
+	Expect(err).To(MatchError(ContainSubstring("EntityAlreadyExists")))
+}
@@ -0,0 +1,378 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+// decoder fills sdk input structs from lua objects.
+//
+// Keys are either the sdk field names (VpcId) or their snake_case form (vpc_id).
+// Pointers, enums, nested structs, slices and maps are handled. A few shorthands are accepted:
+//   - a scalar where a list is expected is a list of one element,
+//   - tags as a map {k = v} for any list of Key/Value structs,
+//   - filters as a map {name = values} for any list of Name/Values structs,
+//   - "tags" as a map for TagSpecifications when tagResourceType is set,
+//   - policy documents as tables which are encoded in json.
+//
+// Unknown keys and values of the wrong type are reported as errors.
+type decoder struct {
+	// aliases maps extra keys to field names of the top level struct.
+	aliases map[string]string
+	// tagResourceType is the resource type of the tag specification built from the "tags" shorthand.
+	tagResourceType string
+}
+
+// decode fills out, which must be a pointer to a struct, from the object.
+func (d decoder) decode(o lua.Object, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode into %T", out)
+	}
+
+	target := v.Elem()
+	if d.tagResourceType != "" {
+		if tags, found := o["tags"]; found {
+			if _, hasTags := fieldsOf(target.Type())["tags"]; !hasTags {
+				o = copyWithout(o, "tags")
+				o["TagSpecifications"] = []interface{}{
+					lua.Object{"ResourceType": d.tagResourceType, "Tags": tags},
+				}
+			}
+		}
+	}
+
+	return decodeStruct("", o, target, d.aliases)
+}
+
+func decodeStruct(path string, o lua.Object, target reflect.Value, aliases map[string]string) error {
+	fields := fieldsOf(target.Type())
+
+	// keys are sorted to always report the same error first.
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := key
+		if alias, found := aliases[key]; found {
+			name = alias
+		}
+		index, found := fields[name]
+		if !found {
+			index, found = fields[toSnakeCase(name)]
+		}
+		if !found {
+			return fmt.Errorf("unknown key %q", joinPath(path, key))
+		}
+
+		if err := decodeValue(joinPath(path, key), o[key], target.FieldByIndex(index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func decodeValue(path string, value interface{}, target reflect.Value) error {
+	if value == nil {
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(target.Type().Elem())
+		if err := decodeValue(path, value, elem.Elem()); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	case reflect.Interface:
+		v := reflect.ValueOf(value)
+		if !v.Type().AssignableTo(target.Type()) {
+			return fmt.Errorf("key %q: unsupported type %s", path, target.Type())
+		}
+		target.Set(v)
+		return nil
+	case reflect.String:
+		return decodeString(path, value, target)
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return typeError(path, "boolean", value)
+		}
+		target.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(float64)
+		if !ok {
+			return typeError(path, "number", value)
+		}
+		if n != math.Trunc(n) || target.OverflowInt(int64(n)) {
+			return fmt.Errorf("key %q: %v is not a valid %s", path, n, target.Type())
+		}
+		target.SetInt(int64(n))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, ok := value.(float64)
+		if !ok {
+			return typeError(path, "number", value)
+		}
+		target.SetFloat(n)
+		return nil
+	case reflect.Struct:
+		if target.Type() == timeType {
+			s, ok := value.(string)
+			if !ok {
+				return typeError(path, "RFC3339 time", value)
+			}
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", path, err)
+			}
+			target.Set(reflect.ValueOf(t))
+			return nil
+		}
+		o, ok := value.(lua.Object)
+		if !ok {
+			return typeError(path, "table", value)
+		}
+		return decodeStruct(path, o, target, nil)
+	case reflect.Slice:
+		return decodeSlice(path, value, target)
+	case reflect.Map:
+		o, ok := value.(lua.Object)
+		if !ok {
+			return typeError(path, "table", value)
+		}
+		m := reflect.MakeMapWithSize(target.Type(), len(o))
+		for k, v := range o {
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if err := decodeValue(joinPath(path, k), v, elem); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(target.Type().Key()), elem)
+		}
+		target.Set(m)
+		return nil
+	}
+
+	return fmt.Errorf("key %q: unsupported type %s", path, target.Type())
+}
+
+func decodeString(path string, value interface{}, target reflect.Value) error {
+	switch v := value.(type) {
+	case string:
+		target.SetString(enumValue(target, v))
+		return nil
+	case lua.Object, []interface{}:
+		if !strings.Contains(path, "Policy") && !strings.Contains(path, "policy") {
+			break
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", path, err)
+		}
+		target.SetString(string(data))
+		return nil
+	}
+	return typeError(path, "string", value)
+}
+
+// enumValue returns the known enum value matching s regardless of the case.
+// Unknown values are returned as is because enums can be extended on the aws side.
+func enumValue(target reflect.Value, s string) string {
+	values := target.MethodByName("Values")
+	if !values.IsValid() && target.CanAddr() {
+		values = target.Addr().MethodByName("Values")
+	}
+	if !values.IsValid() || values.Type().NumIn() != 0 || values.Type().NumOut() != 1 {
+		return s
+	}
+	known := values.Call(nil)[0]
+	if known.Kind() != reflect.Slice {
+		return s
+	}
+	for i := 0; i < known.Len(); i++ {
+		if k := known.Index(i).String(); strings.EqualFold(k, s) {
+			return k
+		}
+	}
+	return s
+}
+
+func decodeSlice(path string, value interface{}, target reflect.Value) error {
+	elemType := target.Type().Elem()
+
+	var items []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		items = v
+	case lua.Object:
+		switch {
+		case len(v) == 0:
+			items = []interface{}{}
+		case isPair(v, "Key", "Value") || isPair(v, "Name", "Values"):
+			// a single element given without the enclosing list.
+			items = []interface{}{v}
+		case hasFields(elemType, "Key", "Value"):
+			items = pairsToList(v, "Key", "Value")
+		case hasFields(elemType, "Name", "Values"):
+			items = pairsToList(v, "Name", "Values")
+		default:
+			return typeError(path, "list", value)
+		}
+	case string:
+		if elemType.Kind() == reflect.Uint8 {
+			target.SetBytes([]byte(v))
+			return nil
+		}
+		items = []interface{}{v}
+	default:
+		items = []interface{}{v}
+	}
+
+	slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := decodeValue(fmt.Sprintf("%s[%d]", path, i+1), item, slice.Index(i)); err != nil {
+			return err
+		}
+	}
+	target.Set(slice)
+	return nil
+}
+
+// pairsToList converts a map into a list of {keyField = k, valueField = v} objects sorted by key.
+func pairsToList(o lua.Object, keyField, valueField string) []interface{} {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := make([]interface{}, 0, len(o))
+	for _, k := range keys {
+		items = append(items, lua.Object{keyField: k, valueField: o[k]})
+	}
+	return items
+}
+
+// isPair returns true if the object holds exactly the key and value fields.
+func isPair(o lua.Object, keyField, valueField string) bool {
+	if len(o) != 2 {
+		return false
+	}
+	for _, field := range []string{keyField, valueField} {
+		_, found := o[field]
+		_, snakeFound := o[toSnakeCase(field)]
+		if !found && !snakeFound {
+			return false
+		}
+	}
+	return true
+}
+
+func hasFields(t reflect.Type, names ...string) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for _, name := range names {
+		if _, found := t.FieldByName(name); !found {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldsOf returns the index of every exported field of t, embedded structs included, by name and by snake_case name.
+func fieldsOf(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for name, index := range fieldsOf(f.Type) {
+				if _, found := fields[name]; !found {
+					fields[name] = append([]int{i}, index...)
+				}
+			}
+			continue
+		}
+		fields[f.Name] = []int{i}
+		fields[toSnakeCase(f.Name)] = []int{i}
+	}
+	return fields
+}
+
+// toSnakeCase converts a sdk field name into its snake_case form: Ipv6CidrBlock -> ipv6_cidr_block.
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) && runes[i-1] != '_' {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func copyWithout(o lua.Object, keys ...string) lua.Object {
+	c := make(lua.Object, len(o))
+	for k, v := range o {
+		c[k] = v
+	}
+	for _, k := range keys {
+		delete(c, k)
+	}
+	return c
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func typeError(path, expected string, value interface{}) error {
+	return fmt.Errorf("key %q: expected %s, got %s", path, expected, luaTypeName(value))
+}
+
+func luaTypeName(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case lua.Object, []interface{}:
+		return "table"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// idAlias returns the aliases mapping the "id" key to the id field of an input.
+func idAlias(field string) map[string]string {
+	return map[string]string{"id": field}
+}
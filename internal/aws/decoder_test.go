@@ -0,0 +1,161 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+func TestDecode(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		name     string
+		decoder  decoder
+		input    lua.Object
+		out      interface{}
+		expected interface{}
+	}{
+		{
+			name:    "pascal case, snake case and aliases",
+			decoder: decoder{aliases: map[string]string{"cidr": "CidrBlock"}},
+			input: lua.Object{
+				"cidr":                 "10.0.1.0/24",
+				"VpcId":                "vpc-1",
+				"availability_zone_id": "euw1-az1",
+				"ipv6_native":          true,
+			},
+			out: &ec2.CreateSubnetInput{},
+			expected: &ec2.CreateSubnetInput{
+				CidrBlock:          aws.String("10.0.1.0/24"),
+				VpcId:              aws.String("vpc-1"),
+				AvailabilityZoneId: aws.String("euw1-az1"),
+				Ipv6Native:         aws.Bool(true),
+			},
+		},
+		{
+			name: "numbers and enums",
+			input: lua.Object{
+				"availability_zone": "eu-west-1a",
+				"size":              float64(20),
+				"volume_type":       "GP3",
+			},
+			out: &ec2.CreateVolumeInput{},
+			expected: &ec2.CreateVolumeInput{
+				AvailabilityZone: aws.String("eu-west-1a"),
+				Size:             aws.Int32(20),
+				VolumeType:       types.VolumeTypeGp3,
+			},
+		},
+		{
+			name:    "tag specifications from tags",
+			decoder: decoder{tagResourceType: string(types.ResourceTypeVpc)},
+			input: lua.Object{
+				"cidr_block": "10.0.0.0/16",
+				"tags":       lua.Object{"myvpc": "true"},
+			},
+			out: &ec2.CreateVpcInput{},
+			expected: &ec2.CreateVpcInput{
+				CidrBlock: aws.String("10.0.0.0/16"),
+				TagSpecifications: []types.TagSpecification{{
+					ResourceType: types.ResourceTypeVpc,
+					Tags:         []types.Tag{{Key: aws.String("myvpc"), Value: aws.String("true")}},
+				}},
+			},
+		},
+		{
+			name: "filters as list and as map",
+			input: lua.Object{
+				"filters": []interface{}{
+					lua.Object{"Name": "vpc-id", "Values": []interface{}{"vpc-1"}},
+				},
+				"subnet_ids": "subnet-1",
+			},
+			out: &ec2.DescribeSubnetsInput{},
+			expected: &ec2.DescribeSubnetsInput{
+				Filters:   []types.Filter{{Name: aws.String("vpc-id"), Values: []string{"vpc-1"}}},
+				SubnetIds: []string{"subnet-1"},
+			},
+		},
+		{
+			name: "filters map",
+			input: lua.Object{
+				"filters": lua.Object{"tag:Name": "web", "state": []interface{}{"available"}},
+			},
+			out: &ec2.DescribeSubnetsInput{},
+			expected: &ec2.DescribeSubnetsInput{
+				Filters: []types.Filter{
+					{Name: aws.String("state"), Values: []string{"available"}},
+					{Name: aws.String("tag:Name"), Values: []string{"web"}},
+				},
+			},
+		},
+		{
+			name: "policy document as table",
+			input: lua.Object{
+				"role_name":                   "role",
+				"assume_role_policy_document": lua.Object{"Version": "2012-10-17"},
+			},
+			out: &iam.CreateRoleInput{},
+			expected: &iam.CreateRoleInput{
+				RoleName:                 aws.String("role"),
+				AssumeRolePolicyDocument: aws.String(`{"Version":"2012-10-17"}`),
+			},
+		},
+		{
+			name: "embedded structs",
+			input: lua.Object{
+				"owners": []interface{}{"amazon"},
+				"latest": true,
+			},
+			out: &imagesInput{},
+			expected: &imagesInput{
+				DescribeImagesInput: ec2.DescribeImagesInput{Owners: []string{"amazon"}},
+				Latest:              true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Expect(tt.decoder.decode(tt.input, tt.out)).To(Succeed())
+			Expect(tt.out).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		name  string
+		input lua.Object
+		err   string
+	}{
+		{name: "unknown key", input: lua.Object{"cidr_blok": "10.0.0.0/16"}, err: `unknown key "cidr_blok"`},
+		{name: "wrong type", input: lua.Object{"cidr_block": float64(10)}, err: `key "cidr_block": expected string, got number`},
+		{name: "wrong nested type", input: lua.Object{"tag_specifications": []interface{}{lua.Object{"Tags": true}}}, err: `key "tag_specifications[1].Tags[1]": expected table, got boolean`},
+		{name: "not an integer", input: lua.Object{"ipv4_netmask_length": 1.5}, err: `key "ipv4_netmask_length": 1.5 is not a valid int32`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decoder{}.decode(tt.input, &ec2.CreateVpcInput{})
+			Expect(err).To(MatchError(tt.err))
+		})
+	}
+}
+
+func TestToSnakeCase(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(toSnakeCase("VpcId")).To(Equal("vpc_id"))
+	Expect(toSnakeCase("Ipv6CidrBlock")).To(Equal("ipv6_cidr_block"))
+	Expect(toSnakeCase("AvailabilityZoneId")).To(Equal("availability_zone_id"))
+	Expect(toSnakeCase("VPCId")).To(Equal("vpc_id"))
+}
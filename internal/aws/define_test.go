@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/lua"
+	glua "github.com/yuin/gopher-lua"
+)
+
+func TestDefine(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := glua.NewState()
+	defer L.Close()
+	L.PreloadModule("aws", lua.NewAwsModule(New(ClientConfiguration{})).Loader)
+
+	script := `
+local aws = require("aws")
+local bastions = {}
+
+local _, err = aws.define("my_bastion", {
+    schema = {
+        name = { type = "string", required = true },
+        vpc_id = { type = "string", format = "id", prefix = "vpc-" },
+    },
+    depends_on = { "aws_vpc" },
+    create = function(input)
+        local b = { id = "bastion-" .. input.name, name = input.name }
+        bastions[b.id] = b
+        return b
+    end,
+    read = function(input)
+        local b = bastions[input.id]
+        if b == nil then
+            return nil, "bastion " .. input.id .. " not found"
+        end
+        return b
+    end,
+    delete = function(input)
+        bastions[input.id] = nil
+    end,
+})
+assert(err == nil, err)
+
+local b, err = aws.create("my_bastion", { name = "one", vpc_id = "vpc-1" })
+assert(err == nil, err)
+assert(b.id == "bastion-one")
+
+local got, err = aws.get("my_bastion", { id = "bastion-one" })
+assert(err == nil, err)
+assert(got.name == "one")
+
+local _, err = aws.create("my_bastion", { vpc_id = "vpc-1" })
+assert(err == 'invalid input: field "name" is required', err)
+
+local _, err = aws.delete("my_bastion", { id = "bastion-one" })
+assert(err == nil, err)
+local _, err = aws.get("my_bastion", { id = "bastion-one" })
+assert(err == "bastion bastion-one not found", err)
+
+local _, err = aws.update("my_bastion", { id = "bastion-one" })
+assert(err == 'resource "my_bastion" does not support update', err)
+
+local _, err = aws.define("aws_vpc", {})
+assert(err ~= nil)
+
+local found = false
+for _, t in ipairs(aws.types()) do
+    if t.name == "my_bastion" then
+        found = true
+        assert(t.depends_on[1] == "aws_vpc")
+    end
+end
+assert(found)
+`
+	Expect(L.DoString(script)).To(Succeed())
+}
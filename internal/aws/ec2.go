@@ -4,60 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/tupyy/aws-lua/internal/lua"
 )
 
-func createEc2Client(ctx context.Context, accessKey, secretKey, region string) (*ec2.Client, error) {
-	optFn := func(opts *awsConfig.LoadOptions) error {
-		opts.Region = region
-		opts.Credentials = getAwsCredentials(ctx, accessKey, secretKey)
-		return nil
-	}
-	awsConfig, err := awsConfig.LoadDefaultConfig(ctx, optFn)
+func createEc2Client(ctx context.Context, config ClientConfiguration) (*ec2.Client, error) {
+	awsConfig, err := config.load(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create aws config for account: %w", err)
-	}
-	return ec2.NewFromConfig(awsConfig), nil
-}
-
-func ec2GetOpFunc(opType OpType, c ClientConfiguration) opFunc {
-	switch opType {
-	case CreateVpc:
-		return createVpc(c)
-	case ListVpcs:
-		return listVpcs(c)
-	case DeleteVpc:
-		return deleteVpc(c)
-	case CreateSubnet:
-		return createSubnet(c)
-	case ListSubnets:
-		return listSubnets(c)
-	case DeleteSubnet:
-		return deleteSubnet(c)
-	case CreateIgw:
-		return createIgw(c)
-	case DeleteIgw:
-		return deleteIgw(c)
-	case ListIgws:
-		return listIgws(c)
-	case ListAvailabilityZones:
-		return listAZs(c)
-	case CreateNat:
-		return createNat(c)
-	case DeleteNat:
-		return deleteNat(c)
-	case ListNats:
-		return listNats(c)
-	default:
-		return func(ctx context.Context, input interface{}) (interface{}, error) {
-			return nil, errors.New("unknown op type for ec2 client")
-		}
+		return nil, err
 	}
+	return ec2.NewFromConfig(awsConfig, func(o *ec2.Options) {
+		if endpoint := config.endpoint("ec2"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
 }
 
 /**
@@ -67,7 +31,7 @@ func ec2GetOpFunc(opType OpType, c ClientConfiguration) opFunc {
 func createVpc(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.CreateVpcInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -82,7 +46,7 @@ func createVpc(config ClientConfiguration) opFunc {
 func listVpcs(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.DescribeVpcsInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -97,7 +61,7 @@ func listVpcs(config ClientConfiguration) opFunc {
 func deleteVpc(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.DeleteVpcInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -112,7 +76,7 @@ func deleteVpc(config ClientConfiguration) opFunc {
 func listAZs(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.DescribeAvailabilityZonesInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -131,7 +95,7 @@ func listAZs(config ClientConfiguration) opFunc {
 func createSubnet(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.CreateSubnetInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -146,7 +110,7 @@ func createSubnet(config ClientConfiguration) opFunc {
 func deleteSubnet(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.DeleteSubnetInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -161,7 +125,7 @@ func deleteSubnet(config ClientConfiguration) opFunc {
 func listSubnets(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.DescribeSubnetsInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -173,13 +137,794 @@ func listSubnets(config ClientConfiguration) opFunc {
 	}
 }
 
+/**
+ Op functions for VPC endpoints
+**/
+
+func createVpcEndpoint(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.CreateVpcEndpointInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.CreateVpcEndpoint(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+func deleteVpcEndpoints(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.DeleteVpcEndpointsInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DeleteVpcEndpoints(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+func listVpcEndpoints(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.DescribeVpcEndpointsInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DescribeVpcEndpoints(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+/**
+ Op functions for VPC peering connections
+**/
+
+// vpcPeeringInput holds everything needed to request a peering connection and,
+// optionally, accept it and set its DNS resolution options.
+type vpcPeeringInput struct {
+	ec2.CreateVpcPeeringConnectionInput
+	AutoAccept bool
+	// Accepter overrides the credentials and the region of the accepter side.
+	Accepter      *accepterConfiguration
+	DnsResolution *dnsResolutionOptions
+
+	// accepter is the configuration used for the accepter side of the connection.
+	accepter ClientConfiguration
+	// accepterCredentials is true when the accepter side has its own credentials.
+	accepterCredentials bool
+	requesterDnsOpts    *types.PeeringConnectionOptionsRequest
+	accepterDnsOpts     *types.PeeringConnectionOptionsRequest
+	acceptWaitTimeout   time.Duration
+}
+
+type accepterConfiguration struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+}
+
+// dnsResolutionOptions allows each side to resolve the private DNS hostnames of the other side.
+// They are set once the connection is accepted, so they require AutoAccept.
+type dnsResolutionOptions struct {
+	Requester *bool
+	Accepter  *bool
+}
+
+type vpcPeeringOutput struct {
+	VpcPeeringConnection *types.VpcPeeringConnection
+}
+
+// checkPeerOwner returns an error when the peer vpc is owned by another account than the requester vpc,
+// the requester credentials being unable to accept the connection.
+func checkPeerOwner(ctx context.Context, ec2Client *ec2.Client, i vpcPeeringInput) error {
+	o, err := ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{VpcIds: []string{aws.ToString(i.VpcId)}})
+	if err != nil {
+		return err
+	}
+	if len(o.Vpcs) == 0 {
+		return fmt.Errorf("vpc %s not found", aws.ToString(i.VpcId))
+	}
+	if owner := aws.ToString(o.Vpcs[0].OwnerId); owner != aws.ToString(i.PeerOwnerId) {
+		return fmt.Errorf("peer_owner_id %s is not the account %s of the requester: auto_accept requires the accepter credentials",
+			aws.ToString(i.PeerOwnerId), owner)
+	}
+	return nil
+}
+
+func createVpcPeering(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(vpcPeeringInput)
+		requesterClient, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		if i.AutoAccept && i.PeerOwnerId != nil && !i.accepterCredentials {
+			// the connection is checked before being created, else it would be left pending.
+			if err := checkPeerOwner(ctx, requesterClient, i); err != nil {
+				return nil, err
+			}
+		}
+		o, err := requesterClient.CreateVpcPeeringConnection(ctx, &i.CreateVpcPeeringConnectionInput)
+		if err != nil {
+			return nil, err
+		}
+		output := vpcPeeringOutput{VpcPeeringConnection: o.VpcPeeringConnection}
+		if !i.AutoAccept || o.VpcPeeringConnection == nil {
+			return output, nil
+		}
+
+		peeringId := o.VpcPeeringConnection.VpcPeeringConnectionId
+		accepterClient, err := createEc2Client(ctx, i.accepter)
+		if err != nil {
+			return output, err
+		}
+
+		// the connection is not visible right away on the accepter side.
+		waiter := ec2.NewVpcPeeringConnectionExistsWaiter(accepterClient)
+		if err := waiter.Wait(ctx, &ec2.DescribeVpcPeeringConnectionsInput{
+			VpcPeeringConnectionIds: []string{*peeringId},
+		}, i.acceptWaitTimeout); err != nil {
+			return output, fmt.Errorf("vpc peering connection %s not visible to accepter: %w", *peeringId, err)
+		}
+
+		accepted, err := accepterClient.AcceptVpcPeeringConnection(ctx, &ec2.AcceptVpcPeeringConnectionInput{
+			VpcPeeringConnectionId: peeringId,
+		})
+		if err != nil {
+			return output, err
+		}
+		output.VpcPeeringConnection = accepted.VpcPeeringConnection
+
+		if i.requesterDnsOpts == nil && i.accepterDnsOpts == nil {
+			return output, nil
+		}
+
+		// each side of the connection has to set its own options unless both sides are the same account and region.
+		if i.accepter.AccessKey == config.AccessKey && i.accepter.SecretKey == config.SecretKey && i.accepter.Region == config.Region {
+			_, err := requesterClient.ModifyVpcPeeringConnectionOptions(ctx, &ec2.ModifyVpcPeeringConnectionOptionsInput{
+				VpcPeeringConnectionId:            peeringId,
+				RequesterPeeringConnectionOptions: i.requesterDnsOpts,
+				AccepterPeeringConnectionOptions:  i.accepterDnsOpts,
+			})
+			return output, err
+		}
+
+		if i.requesterDnsOpts != nil {
+			if _, err := requesterClient.ModifyVpcPeeringConnectionOptions(ctx, &ec2.ModifyVpcPeeringConnectionOptionsInput{
+				VpcPeeringConnectionId:            peeringId,
+				RequesterPeeringConnectionOptions: i.requesterDnsOpts,
+			}); err != nil {
+				return output, err
+			}
+		}
+		if i.accepterDnsOpts != nil {
+			if _, err := accepterClient.ModifyVpcPeeringConnectionOptions(ctx, &ec2.ModifyVpcPeeringConnectionOptionsInput{
+				VpcPeeringConnectionId:           peeringId,
+				AccepterPeeringConnectionOptions: i.accepterDnsOpts,
+			}); err != nil {
+				return output, err
+			}
+		}
+
+		return output, nil
+	}
+}
+
+func deleteVpcPeering(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.DeleteVpcPeeringConnectionInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DeleteVpcPeeringConnection(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+func listVpcPeerings(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.DescribeVpcPeeringConnectionsInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DescribeVpcPeeringConnections(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+/**
+ Op functions for network ACLs
+**/
+
+// networkAclInput holds the declared state of a network ACL.
+// Entries and SubnetIds are reconciled only when set.
+type networkAclInput struct {
+	ec2.CreateNetworkAclInput
+	NetworkAclId string
+	Entries      []lua.Object
+	SubnetIds    []string
+
+	entries []types.NetworkAclEntry
+}
+
+type networkAclOutput struct {
+	NetworkAcl *types.NetworkAcl
+}
+
+// defaultNetworkAclRuleNumber is the number of the ipv4 catch-all deny rule. The ipv6 one is numbered 32768.
+// These rules cannot be changed, the declared rules are numbered from 1 to maxNetworkAclRuleNumber.
+const (
+	defaultNetworkAclRuleNumber = 32767
+	maxNetworkAclRuleNumber     = defaultNetworkAclRuleNumber - 1
+)
+
+func createNetworkAcl(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(networkAclInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.CreateNetworkAcl(ctx, &i.CreateNetworkAclInput)
+		if err != nil {
+			return nil, err
+		}
+		i.NetworkAclId = *o.NetworkAcl.NetworkAclId
+		return reconcileNetworkAcl(ctx, ec2Client, i)
+	}
+}
+
+func updateNetworkAcl(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(networkAclInput)
+		if i.NetworkAclId == "" {
+			return nil, errors.New("network_acl_id is required")
+		}
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		return reconcileNetworkAcl(ctx, ec2Client, i)
+	}
+}
+
+func deleteNetworkAcl(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.DeleteNetworkAclInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DeleteNetworkAcl(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+func listNetworkAcls(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.DescribeNetworkAclsInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DescribeNetworkAcls(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+// reconcileNetworkAcl makes the entries and the subnet associations of the acl match exactly the declared ones.
+func reconcileNetworkAcl(ctx context.Context, ec2Client *ec2.Client, i networkAclInput) (networkAclOutput, error) {
+	acl, err := describeNetworkAcl(ctx, ec2Client, i.NetworkAclId)
+	if err != nil {
+		return networkAclOutput{}, err
+	}
+
+	if i.entries != nil {
+		toCreate, toReplace, toDelete := diffNetworkAclEntries(acl.Entries, i.entries)
+		for _, e := range toDelete {
+			if _, err := ec2Client.DeleteNetworkAclEntry(ctx, &ec2.DeleteNetworkAclEntryInput{
+				NetworkAclId: acl.NetworkAclId,
+				Egress:       e.Egress,
+				RuleNumber:   e.RuleNumber,
+			}); err != nil {
+				return networkAclOutput{}, fmt.Errorf("failed to delete entry %d: %w", aws.ToInt32(e.RuleNumber), err)
+			}
+		}
+		for _, e := range toReplace {
+			if _, err := ec2Client.ReplaceNetworkAclEntry(ctx, &ec2.ReplaceNetworkAclEntryInput{
+				NetworkAclId:  acl.NetworkAclId,
+				Egress:        e.Egress,
+				RuleNumber:    e.RuleNumber,
+				Protocol:      e.Protocol,
+				RuleAction:    e.RuleAction,
+				CidrBlock:     e.CidrBlock,
+				Ipv6CidrBlock: e.Ipv6CidrBlock,
+				PortRange:     e.PortRange,
+				IcmpTypeCode:  e.IcmpTypeCode,
+			}); err != nil {
+				return networkAclOutput{}, fmt.Errorf("failed to replace entry %d: %w", aws.ToInt32(e.RuleNumber), err)
+			}
+		}
+		for _, e := range toCreate {
+			if _, err := ec2Client.CreateNetworkAclEntry(ctx, &ec2.CreateNetworkAclEntryInput{
+				NetworkAclId:  acl.NetworkAclId,
+				Egress:        e.Egress,
+				RuleNumber:    e.RuleNumber,
+				Protocol:      e.Protocol,
+				RuleAction:    e.RuleAction,
+				CidrBlock:     e.CidrBlock,
+				Ipv6CidrBlock: e.Ipv6CidrBlock,
+				PortRange:     e.PortRange,
+				IcmpTypeCode:  e.IcmpTypeCode,
+			}); err != nil {
+				return networkAclOutput{}, fmt.Errorf("failed to create entry %d: %w", aws.ToInt32(e.RuleNumber), err)
+			}
+		}
+	}
+
+	if i.SubnetIds != nil {
+		if err := reconcileNetworkAclAssociations(ctx, ec2Client, acl, i.SubnetIds); err != nil {
+			return networkAclOutput{}, err
+		}
+	}
+
+	acl, err = describeNetworkAcl(ctx, ec2Client, i.NetworkAclId)
+	if err != nil {
+		return networkAclOutput{}, err
+	}
+	return networkAclOutput{NetworkAcl: acl}, nil
+}
+
+// reconcileNetworkAclAssociations associates the subnets with the acl.
+// Subnets associated with the acl but not declared are moved back to the default acl of the vpc.
+func reconcileNetworkAclAssociations(ctx context.Context, ec2Client *ec2.Client, acl *types.NetworkAcl, subnetIds []string) error {
+	declared := make(map[string]bool, len(subnetIds))
+	for _, id := range subnetIds {
+		declared[id] = true
+	}
+
+	var defaultAcl *types.NetworkAcl
+	for _, a := range acl.Associations {
+		subnetId := aws.ToString(a.SubnetId)
+		if declared[subnetId] {
+			delete(declared, subnetId)
+			continue
+		}
+		if defaultAcl == nil {
+			o, err := ec2Client.DescribeNetworkAcls(ctx, &ec2.DescribeNetworkAclsInput{
+				Filters: []types.Filter{
+					{Name: aws.String("vpc-id"), Values: []string{aws.ToString(acl.VpcId)}},
+					{Name: aws.String("default"), Values: []string{"true"}},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if len(o.NetworkAcls) == 0 {
+				return fmt.Errorf("no default network acl found for vpc %s", aws.ToString(acl.VpcId))
+			}
+			defaultAcl = &o.NetworkAcls[0]
+		}
+		if _, err := ec2Client.ReplaceNetworkAclAssociation(ctx, &ec2.ReplaceNetworkAclAssociationInput{
+			AssociationId: a.NetworkAclAssociationId,
+			NetworkAclId:  defaultAcl.NetworkAclId,
+		}); err != nil {
+			return fmt.Errorf("failed to move subnet %s to default network acl: %w", subnetId, err)
+		}
+	}
+
+	// every subnet is always associated with one acl so the association to be replaced must be found first.
+	for subnetId := range declared {
+		o, err := ec2Client.DescribeNetworkAcls(ctx, &ec2.DescribeNetworkAclsInput{
+			Filters: []types.Filter{{Name: aws.String("association.subnet-id"), Values: []string{subnetId}}},
+		})
+		if err != nil {
+			return err
+		}
+		associationId := findNetworkAclAssociation(o.NetworkAcls, subnetId)
+		if associationId == nil {
+			return fmt.Errorf("no network acl association found for subnet %s", subnetId)
+		}
+		if _, err := ec2Client.ReplaceNetworkAclAssociation(ctx, &ec2.ReplaceNetworkAclAssociationInput{
+			AssociationId: associationId,
+			NetworkAclId:  acl.NetworkAclId,
+		}); err != nil {
+			return fmt.Errorf("failed to associate subnet %s: %w", subnetId, err)
+		}
+	}
+
+	return nil
+}
+
+func describeNetworkAcl(ctx context.Context, ec2Client *ec2.Client, id string) (*types.NetworkAcl, error) {
+	o, err := ec2Client.DescribeNetworkAcls(ctx, &ec2.DescribeNetworkAclsInput{NetworkAclIds: []string{id}})
+	if err != nil {
+		return nil, err
+	}
+	if len(o.NetworkAcls) == 0 {
+		return nil, fmt.Errorf("network acl %s not found", id)
+	}
+	return &o.NetworkAcls[0], nil
+}
+
+func findNetworkAclAssociation(acls []types.NetworkAcl, subnetId string) *string {
+	for _, acl := range acls {
+		for _, a := range acl.Associations {
+			if aws.ToString(a.SubnetId) == subnetId {
+				return a.NetworkAclAssociationId
+			}
+		}
+	}
+	return nil
+}
+
+// diffNetworkAclEntries computes the entries to create, replace and delete to go from current entries to the declared ones.
+// Entries are identified by their direction and rule number. The default rules are never touched.
+func diffNetworkAclEntries(current, declared []types.NetworkAclEntry) (toCreate, toReplace, toDelete []types.NetworkAclEntry) {
+	key := func(e types.NetworkAclEntry) string {
+		return fmt.Sprintf("%t/%d", aws.ToBool(e.Egress), aws.ToInt32(e.RuleNumber))
+	}
+
+	existing := make(map[string]types.NetworkAclEntry, len(current))
+	for _, e := range current {
+		if aws.ToInt32(e.RuleNumber) >= defaultNetworkAclRuleNumber {
+			continue
+		}
+		existing[key(e)] = e
+	}
+
+	for _, e := range declared {
+		c, found := existing[key(e)]
+		if !found {
+			toCreate = append(toCreate, e)
+			continue
+		}
+		delete(existing, key(e))
+		if !networkAclEntryEqual(c, e) {
+			toReplace = append(toReplace, e)
+		}
+	}
+
+	for _, e := range current {
+		if _, found := existing[key(e)]; found {
+			toDelete = append(toDelete, e)
+		}
+	}
+
+	return toCreate, toReplace, toDelete
+}
+
+func networkAclEntryEqual(a, b types.NetworkAclEntry) bool {
+	if aws.ToString(a.Protocol) != aws.ToString(b.Protocol) ||
+		a.RuleAction != b.RuleAction ||
+		aws.ToString(a.CidrBlock) != aws.ToString(b.CidrBlock) ||
+		aws.ToString(a.Ipv6CidrBlock) != aws.ToString(b.Ipv6CidrBlock) {
+		return false
+	}
+
+	// ports and icmp codes are only meaningful for some protocols.
+	switch aws.ToString(a.Protocol) {
+	case "6", "17":
+		var aFrom, aTo, bFrom, bTo int32
+		if a.PortRange != nil {
+			aFrom, aTo = aws.ToInt32(a.PortRange.From), aws.ToInt32(a.PortRange.To)
+		}
+		if b.PortRange != nil {
+			bFrom, bTo = aws.ToInt32(b.PortRange.From), aws.ToInt32(b.PortRange.To)
+		}
+		return aFrom == bFrom && aTo == bTo
+	case "1", "58":
+		var aType, aCode, bType, bCode int32
+		if a.IcmpTypeCode != nil {
+			aType, aCode = aws.ToInt32(a.IcmpTypeCode.Type), aws.ToInt32(a.IcmpTypeCode.Code)
+		}
+		if b.IcmpTypeCode != nil {
+			bType, bCode = aws.ToInt32(b.IcmpTypeCode.Type), aws.ToInt32(b.IcmpTypeCode.Code)
+		}
+		return aType == bType && aCode == bCode
+	}
+
+	return true
+}
+
+/**
+ Op functions for EBS volumes and snapshots
+**/
+
+// volumeInput holds the calls to be made on a volume.
+// The volume is attached when both InstanceId and Device are set and detached when Detach is set.
+type volumeInput struct {
+	ec2.CreateVolumeInput
+	VolumeId   string
+	InstanceId string
+	Device     string
+	Detach     bool
+	Force      *bool
+
+	attach *ec2.AttachVolumeInput
+	detach *ec2.DetachVolumeInput
+}
+
+type volumeOutput struct {
+	Volume *types.Volume
+}
+
+// snapshotInput holds either a snapshot creation or a snapshot copy.
+type snapshotInput struct {
+	create *ec2.CreateSnapshotInput
+	copy   *ec2.CopySnapshotInput
+}
+
+type snapshotOutput struct {
+	Snapshot *types.Snapshot
+}
+
+// volumeWaitTimeout is the maximum time to wait for a volume to change state.
+const volumeWaitTimeout = 5 * time.Minute
+
+func createVolume(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(volumeInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.CreateVolume(ctx, &i.CreateVolumeInput)
+		if err != nil {
+			return nil, err
+		}
+
+		if i.attach != nil {
+			if err := attachNewVolume(ctx, ec2Client, *o.VolumeId, i.attach); err != nil {
+				// the volume is deleted so that a failed create leaves nothing behind.
+				if _, deleteErr := ec2Client.DeleteVolume(ctx, &ec2.DeleteVolumeInput{VolumeId: o.VolumeId}); deleteErr != nil {
+					return nil, fmt.Errorf("%w (volume %s not deleted: %s)", err, *o.VolumeId, deleteErr)
+				}
+				return nil, err
+			}
+		}
+
+		return describeVolume(ctx, ec2Client, *o.VolumeId)
+	}
+}
+
+// attachNewVolume waits for the volume to be created and attaches it.
+func attachNewVolume(ctx context.Context, ec2Client *ec2.Client, volumeId string, attach *ec2.AttachVolumeInput) error {
+	// the volume cannot be attached while it is being created.
+	waiter := ec2.NewVolumeAvailableWaiter(ec2Client)
+	if err := waiter.Wait(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{volumeId}}, volumeWaitTimeout); err != nil {
+		return fmt.Errorf("volume %s not available: %w", volumeId, err)
+	}
+	attach.VolumeId = aws.String(volumeId)
+	if _, err := ec2Client.AttachVolume(ctx, attach); err != nil {
+		return fmt.Errorf("failed to attach volume %s: %w", volumeId, err)
+	}
+	return nil
+}
+
+// updateVolume attaches or detaches the volume.
+func updateVolume(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(volumeInput)
+		if i.VolumeId == "" {
+			return nil, errors.New("volume_id is required")
+		}
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+
+		if i.detach != nil {
+			i.detach.VolumeId = aws.String(i.VolumeId)
+			if _, err := ec2Client.DetachVolume(ctx, i.detach); err != nil {
+				return nil, err
+			}
+		}
+		if i.attach != nil {
+			i.attach.VolumeId = aws.String(i.VolumeId)
+			if _, err := ec2Client.AttachVolume(ctx, i.attach); err != nil {
+				return nil, err
+			}
+		}
+
+		return describeVolume(ctx, ec2Client, i.VolumeId)
+	}
+}
+
+func deleteVolume(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.DeleteVolumeInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DeleteVolume(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+func listVolumes(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.DescribeVolumesInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DescribeVolumes(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+func describeVolume(ctx context.Context, ec2Client *ec2.Client, id string) (volumeOutput, error) {
+	o, err := ec2Client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{id}})
+	if err != nil {
+		return volumeOutput{}, err
+	}
+	if len(o.Volumes) == 0 {
+		return volumeOutput{}, fmt.Errorf("volume %s not found", id)
+	}
+	return volumeOutput{Volume: &o.Volumes[0]}, nil
+}
+
+// createSnapshot creates a snapshot of a volume or copies an existing snapshot into the configured region.
+func createSnapshot(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(snapshotInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+
+		var snapshotId *string
+		switch {
+		case i.copy != nil:
+			o, err := ec2Client.CopySnapshot(ctx, i.copy)
+			if err != nil {
+				return nil, err
+			}
+			snapshotId = o.SnapshotId
+		case i.create != nil:
+			o, err := ec2Client.CreateSnapshot(ctx, i.create)
+			if err != nil {
+				return nil, err
+			}
+			snapshotId = o.SnapshotId
+		default:
+			return nil, errors.New("either volume_id or source_snapshot_id is required")
+		}
+
+		o, err := ec2Client.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{SnapshotIds: []string{*snapshotId}})
+		if err != nil {
+			return nil, err
+		}
+		if len(o.Snapshots) == 0 {
+			return nil, fmt.Errorf("snapshot %s not found", *snapshotId)
+		}
+		return snapshotOutput{Snapshot: &o.Snapshots[0]}, nil
+	}
+}
+
+func deleteSnapshot(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.DeleteSnapshotInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DeleteSnapshot(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+func listSnapshots(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(ec2.DescribeSnapshotsInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DescribeSnapshots(ctx, &i)
+		if err != nil {
+			return nil, err
+		}
+		return *o, nil
+	}
+}
+
+/**
+ Op functions for AMIs
+**/
+
+// imagesInput describes images. If Latest is set only the most recent non deprecated image is returned.
+type imagesInput struct {
+	ec2.DescribeImagesInput
+	Latest bool
+}
+
+func listImages(config ClientConfiguration) opFunc {
+	return func(ctx context.Context, input interface{}) (interface{}, error) {
+		i := input.(imagesInput)
+		ec2Client, err := createEc2Client(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := ec2Client.DescribeImages(ctx, &i.DescribeImagesInput)
+		if err != nil {
+			return nil, err
+		}
+		if !i.Latest {
+			return *o, nil
+		}
+
+		image := latestImage(o.Images, time.Now())
+		if image == nil {
+			return nil, errors.New("no image found")
+		}
+		o.Images = []types.Image{*image}
+		return *o, nil
+	}
+}
+
+// latestImage returns the image with the most recent creation date which is not deprecated at the given time.
+func latestImage(images []types.Image, now time.Time) *types.Image {
+	var latest *types.Image
+	for idx := range images {
+		image := &images[idx]
+		if image.DeprecationTime != nil {
+			deprecation, err := time.Parse(time.RFC3339, *image.DeprecationTime)
+			if err == nil && !deprecation.After(now) {
+				continue
+			}
+		}
+		// creation dates are ISO 8601 strings in UTC so they can be compared as strings.
+		if latest == nil || aws.ToString(image.CreationDate) > aws.ToString(latest.CreationDate) {
+			latest = image
+		}
+	}
+	return latest
+}
+
 /**
  Op for IGW
 **/
 func createIgw(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.CreateInternetGatewayInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -194,7 +939,7 @@ func createIgw(config ClientConfiguration) opFunc {
 func deleteIgw(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.DeleteInternetGatewayInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -209,7 +954,7 @@ func deleteIgw(config ClientConfiguration) opFunc {
 func listIgws(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.DescribeInternetGatewaysInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -227,7 +972,7 @@ func listIgws(config ClientConfiguration) opFunc {
 func createNat(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.CreateNatGatewayInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -242,7 +987,7 @@ func createNat(config ClientConfiguration) opFunc {
 func deleteNat(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.DeleteNatGatewayInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -257,7 +1002,7 @@ func deleteNat(config ClientConfiguration) opFunc {
 func listNats(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(ec2.DescribeNatGatewaysInput)
-		ec2Client, err := createEc2Client(ctx, config.AccessKey, config.SecretKey, config.Region)
+		ec2Client, err := createEc2Client(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -271,181 +1016,588 @@ func listNats(config ClientConfiguration) opFunc {
 
 /**
  transform functions
-	All these functions do not return error if required data is missing just return empty respose struct.
-	Calling aws with empty input struct will fail.
+	Inputs are decoded from the lua object and any sdk field can be set either by its name or its snake_case name.
+	The legacy keys (e.g. cidr) are kept as aliases.
 **/
-func toCreateVpcInput(o lua.Object) ec2.CreateVpcInput {
-	input := ec2.CreateVpcInput{}
-	cidr := o.GetString("cidr")
-	if cidr == "" {
-		return input
+// Schemas of the inputs validated before calling aws.
+var (
+	vpcSchema = &schema{
+		fields: map[string]field{
+			"cidr_block":                      {kind: stringKind, format: cidrFormat, aliases: []string{"cidr"}},
+			"ipv4_ipam_pool_id":               idField("ipam-pool-"),
+			"amazon_provided_ipv6_cidr_block": {kind: boolKind},
+			"instance_tenancy":                {kind: stringKind, enum: enumOf(types.Tenancy("").Values())},
+		},
+		oneOf: []string{"cidr_block", "ipv4_ipam_pool_id"},
+	}
+
+	subnetSchema = &schema{
+		fields: map[string]field{
+			"vpc_id":               required(idField("vpc-")),
+			"cidr_block":           {kind: stringKind, format: cidrFormat, aliases: []string{"cidr"}},
+			"ipv6_cidr_block":      {kind: stringKind, format: ipv6CidrFormat},
+			"ipv4_ipam_pool_id":    idField("ipam-pool-"),
+			"availability_zone":    {kind: stringKind},
+			"availability_zone_id": {kind: stringKind},
+		},
+		oneOf: []string{"cidr_block", "ipv6_cidr_block", "ipv4_ipam_pool_id"},
+	}
+
+	vpcEndpointSchema = &schema{
+		fields: map[string]field{
+			"vpc_id":             required(idField("vpc-")),
+			"service_name":       {kind: stringKind, required: true},
+			"vpc_endpoint_type":  {kind: stringKind, enum: enumOf(types.VpcEndpointType("").Values()), aliases: []string{"type"}},
+			"subnet_ids":         idListField("subnet-"),
+			"security_group_ids": idListField("sg-"),
+			"route_table_ids":    idListField("rtb-"),
+		},
+	}
+
+	vpcPeeringSchema = &schema{
+		fields: map[string]field{
+			"vpc_id":         required(idField("vpc-")),
+			"peer_vpc_id":    required(idField("vpc-")),
+			"peer_owner_id":  {kind: stringKind},
+			"peer_region":    {kind: stringKind},
+			"auto_accept":    {kind: boolKind},
+			"accepter":       {kind: tableKind},
+			"dns_resolution": {kind: tableKind},
+		},
+	}
+
+	networkAclSchema = &schema{
+		fields: map[string]field{
+			"vpc_id":     required(idField("vpc-")),
+			"entries":    {kind: listKind},
+			"subnet_ids": idListField("subnet-"),
+		},
+	}
+
+	networkAclUpdateSchema = &schema{
+		fields: map[string]field{
+			"network_acl_id": required(idField("acl-")),
+			"entries":        {kind: listKind},
+			"subnet_ids":     idListField("subnet-"),
+		},
+	}
+
+	volumeSchema = &schema{
+		fields: map[string]field{
+			"availability_zone": {kind: stringKind, required: true},
+			"size":              {kind: numberKind},
+			"snapshot_id":       idField("snap-"),
+			"volume_type":       {kind: stringKind, enum: enumOf(types.VolumeType("").Values())},
+			"iops":              {kind: numberKind},
+			"throughput":        {kind: numberKind},
+			"encrypted":         {kind: boolKind},
+			"instance_id":       idField("i-"),
+			"device":            {kind: stringKind},
+		},
+		oneOf: []string{"size", "snapshot_id"},
 	}
 
-	input.CidrBlock = aws.String(cidr)
-	awsTags := createTags(getObject(o, "tags"))
-	if len(awsTags) > 0 {
-		input.TagSpecifications = []types.TagSpecification{{
-			ResourceType: types.ResourceTypeVpc,
-			Tags:         awsTags,
-		}}
+	volumeUpdateSchema = &schema{
+		fields: map[string]field{
+			"volume_id":   required(idField("vol-")),
+			"instance_id": idField("i-"),
+			"device":      {kind: stringKind},
+			"detach":      {kind: boolKind},
+			"force":       {kind: boolKind},
+		},
 	}
 
-	return input
+	snapshotSchema = &schema{
+		fields: map[string]field{
+			"volume_id":          idField("vol-"),
+			"source_snapshot_id": idField("snap-"),
+			"source_region":      {kind: stringKind},
+			"description":        {kind: stringKind},
+		},
+		oneOf: []string{"volume_id", "source_snapshot_id"},
+	}
+
+	natGatewaySchema = &schema{
+		fields: map[string]field{
+			"subnet_id":         required(idField("subnet-")),
+			"allocation_id":     idField("eipalloc-"),
+			"connectivity_type": {kind: stringKind, enum: enumOf(types.ConnectivityType("").Values())},
+		},
+	}
+)
+
+func toCreateVpcInput(o lua.Object) (ec2.CreateVpcInput, error) {
+	input := ec2.CreateVpcInput{}
+	d := decoder{
+		aliases:         map[string]string{"cidr": "CidrBlock"},
+		tagResourceType: string(types.ResourceTypeVpc),
+	}
+	err := d.decode(o, &input)
+	return input, err
 }
 
 func fromCreateVpcOutput(o ec2.CreateVpcOutput) lua.Object {
-	return toLua(o)
+	return normalizeOne(vpcShape, o.Vpc)
 }
 
-func toDescribeVpcsInput(o lua.Object) ec2.DescribeVpcsInput {
-	return ec2.DescribeVpcsInput{}
+func toDescribeVpcsInput(o lua.Object) (ec2.DescribeVpcsInput, error) {
+	input := ec2.DescribeVpcsInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
 }
 
-func fromDescribeVpcsOutput(o ec2.DescribeVpcsOutput) lua.Object {
+func fromDescribeVpcsOutput(o ec2.DescribeVpcsOutput) []lua.Object {
+	return normalizeList(vpcShape, o.Vpcs)
+}
+
+func toDeleteVpcInput(o lua.Object) (ec2.DeleteVpcInput, error) {
+	input := ec2.DeleteVpcInput{}
+	err := decoder{aliases: idAlias("VpcId")}.decode(o, &input)
+	return input, err
+}
+
+func fromDeleteVpcOutput(o ec2.DeleteVpcOutput) lua.Object {
 	return toLua(o)
 }
 
-func toCreateSubnetInput(o lua.Object) ec2.CreateSubnetInput {
+func toCreateSubnetInput(o lua.Object) (ec2.CreateSubnetInput, error) {
 	input := ec2.CreateSubnetInput{}
+	d := decoder{
+		aliases:         map[string]string{"cidr": "CidrBlock"},
+		tagResourceType: string(types.ResourceTypeSubnet),
+	}
+	err := d.decode(o, &input)
+	return input, err
+}
 
-	cidr := o.GetString("cidr")
-	vpc_id := o.GetString("vpc_id")
-	if cidr == "" || vpc_id == "" {
-		return input
+func fromCreateSubnetOutput(o ec2.CreateSubnetOutput) lua.Object {
+	return normalizeOne(subnetShape, o.Subnet)
+}
+
+func toDescribeSubnetsInput(o lua.Object) (ec2.DescribeSubnetsInput, error) {
+	input := ec2.DescribeSubnetsInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
+}
+
+func fromDescribeSubnetsOutput(o ec2.DescribeSubnetsOutput) []lua.Object {
+	return normalizeList(subnetShape, o.Subnets)
+}
+
+func toDeleteSubnetInput(o lua.Object) (ec2.DeleteSubnetInput, error) {
+	input := ec2.DeleteSubnetInput{}
+	err := decoder{aliases: idAlias("SubnetId")}.decode(o, &input)
+	return input, err
+}
+
+func fromDeleteSubnetOutput(o ec2.DeleteSubnetOutput) lua.Object {
+	return toLua(o)
+}
+
+func toCreateVpcEndpointInput(o lua.Object) (ec2.CreateVpcEndpointInput, error) {
+	input := ec2.CreateVpcEndpointInput{}
+
+	// dns_record_ip_type is a shorthand for dns_options.
+	if recordType, found := o["dns_record_ip_type"]; found {
+		o = copyWithout(o, "dns_record_ip_type")
+		o["DnsOptions"] = lua.Object{"DnsRecordIpType": recordType}
 	}
-	input.VpcId = aws.String(vpc_id)
-	input.CidrBlock = aws.String(cidr)
 
-	az := o.GetString("availability_zone_id")
-	if az != "" {
-		input.AvailabilityZoneId = aws.String(az)
+	d := decoder{
+		aliases: map[string]string{
+			"type":   "VpcEndpointType",
+			"policy": "PolicyDocument",
+		},
+		tagResourceType: string(types.ResourceTypeVpcEndpoint),
 	}
+	err := d.decode(o, &input)
+	return input, err
+}
+
+func fromCreateVpcEndpointOutput(o ec2.CreateVpcEndpointOutput) lua.Object {
+	return normalizeOne(vpcEndpointShape, o.VpcEndpoint)
+}
 
-	awsTags := createTags(getObject(o, "tags"))
-	if len(awsTags) > 0 {
-		input.TagSpecifications = []types.TagSpecification{{
-			ResourceType: types.ResourceTypeSubnet,
-			Tags:         awsTags,
-		}}
+func toDescribeVpcEndpointsInput(o lua.Object) (ec2.DescribeVpcEndpointsInput, error) {
+	input := ec2.DescribeVpcEndpointsInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
+}
+
+func fromDescribeVpcEndpointsOutput(o ec2.DescribeVpcEndpointsOutput) []lua.Object {
+	return normalizeList(vpcEndpointShape, o.VpcEndpoints)
+}
+
+func toDeleteVpcEndpointsInput(o lua.Object) (ec2.DeleteVpcEndpointsInput, error) {
+	input := ec2.DeleteVpcEndpointsInput{}
+	d := decoder{
+		aliases: map[string]string{
+			"id":              "VpcEndpointIds",
+			"vpc_endpoint_id": "VpcEndpointIds",
+		},
 	}
-	return input
+	err := d.decode(o, &input)
+	return input, err
 }
 
-func fromCreateSubnetOutput(o ec2.CreateSubnetOutput) lua.Object {
+func fromDeleteVpcEndpointsOutput(o ec2.DeleteVpcEndpointsOutput) lua.Object {
 	return toLua(o)
 }
 
-func toDescribeSubnetsInput(o lua.Object) ec2.DescribeSubnetsInput {
-	input := ec2.DescribeSubnetsInput{}
+// toVpcPeeringInput creates the peering input.
+// The accepter side uses the requester configuration unless it is overridden by the "accepter" table.
+// The accepter region defaults to peer_region.
+func toVpcPeeringInput(config ClientConfiguration) func(o lua.Object) (vpcPeeringInput, error) {
+	return func(o lua.Object) (vpcPeeringInput, error) {
+		input := vpcPeeringInput{}
+		d := decoder{
+			tagResourceType: string(types.ResourceTypeVpcPeeringConnection),
+		}
+		if err := d.decode(o, &input); err != nil {
+			return input, err
+		}
 
-	awsFilters := createFilters(o.GetList("filters"))
-	if len(awsFilters) > 0 {
-		input.Filters = awsFilters
+		input.accepter = config
+		input.acceptWaitTimeout = 2 * time.Minute
+		if input.PeerRegion != nil {
+			input.accepter.Region = *input.PeerRegion
+		}
+		if input.Accepter != nil {
+			if input.Accepter.AccessKey != "" {
+				input.accepter.AccessKey = input.Accepter.AccessKey
+				input.accepter.SecretKey = input.Accepter.SecretKey
+				input.accepterCredentials = true
+			}
+			if input.Accepter.Region != "" {
+				input.accepter.Region = input.Accepter.Region
+			}
+		}
+
+		if input.DnsResolution != nil {
+			// the options can only be set once the connection is active.
+			if !input.AutoAccept {
+				return input, errors.New("dns_resolution requires auto_accept")
+			}
+			if input.DnsResolution.Requester != nil {
+				input.requesterDnsOpts = &types.PeeringConnectionOptionsRequest{AllowDnsResolutionFromRemoteVpc: input.DnsResolution.Requester}
+			}
+			if input.DnsResolution.Accepter != nil {
+				input.accepterDnsOpts = &types.PeeringConnectionOptionsRequest{AllowDnsResolutionFromRemoteVpc: input.DnsResolution.Accepter}
+			}
+		}
+
+		return input, nil
 	}
-	return input
 }
 
-func fromDescribeSubnetsOutput(o ec2.DescribeSubnetsOutput) lua.Object {
-	return toLua(o)
+func fromVpcPeeringOutput(o vpcPeeringOutput) lua.Object {
+	return normalizeOne(vpcPeeringShape, o.VpcPeeringConnection)
 }
 
-func toDescribeAZsInput(o lua.Object) ec2.DescribeAvailabilityZonesInput {
-	return ec2.DescribeAvailabilityZonesInput{}
+func toDescribeVpcPeeringsInput(o lua.Object) (ec2.DescribeVpcPeeringConnectionsInput, error) {
+	input := ec2.DescribeVpcPeeringConnectionsInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
 }
 
-func fromDescribeAZsOutput(o ec2.DescribeAvailabilityZonesOutput) lua.Object {
-	return toLua(o)
+func fromDescribeVpcPeeringsOutput(o ec2.DescribeVpcPeeringConnectionsOutput) []lua.Object {
+	return normalizeList(vpcPeeringShape, o.VpcPeeringConnections)
 }
 
-func toCreateIgwInput(o lua.Object) ec2.CreateInternetGatewayInput {
-	// TODO impl
-	return ec2.CreateInternetGatewayInput{}
+func toDeleteVpcPeeringInput(o lua.Object) (ec2.DeleteVpcPeeringConnectionInput, error) {
+	input := ec2.DeleteVpcPeeringConnectionInput{}
+	err := decoder{aliases: idAlias("VpcPeeringConnectionId")}.decode(o, &input)
+	return input, err
 }
 
-func fromCreateIgwOutput(o ec2.CreateInternetGatewayOutput) lua.Object {
+func fromDeleteVpcPeeringOutput(o ec2.DeleteVpcPeeringConnectionOutput) lua.Object {
 	return toLua(o)
 }
 
-func toDeleteIgwInput(o lua.Object) ec2.DeleteInternetGatewayInput {
-	return ec2.DeleteInternetGatewayInput{}
+// networkAclProtocols maps protocol names to the numbers expected by aws.
+var networkAclProtocols = map[string]string{
+	"all":    "-1",
+	"icmp":   "1",
+	"tcp":    "6",
+	"udp":    "17",
+	"icmpv6": "58",
 }
 
-func fromDeleteIgwOutput(o ec2.DeleteInternetGatewayOutput) lua.Object {
-	return toLua(o)
+func toNetworkAclInput(o lua.Object) (networkAclInput, error) {
+	input := networkAclInput{}
+	d := decoder{
+		tagResourceType: string(types.ResourceTypeNetworkAcl),
+	}
+	if err := d.decode(o, &input); err != nil {
+		return input, err
+	}
+
+	// an empty list means that all the entries must be removed.
+	if input.Entries != nil {
+		input.entries = make([]types.NetworkAclEntry, 0, len(input.Entries))
+		for i, e := range input.Entries {
+			entry, err := toNetworkAclEntry(e)
+			if err != nil {
+				return input, fmt.Errorf("entries[%d]: %w", i+1, err)
+			}
+			input.entries = append(input.entries, entry)
+		}
+	}
+
+	return input, nil
 }
 
-func toDescribeIgwInput(o lua.Object) ec2.DescribeInternetGatewaysInput {
-	// TODO
-	return ec2.DescribeInternetGatewaysInput{}
+// toNetworkAclEntry decodes an entry. Protocols can be given by name, ports with from_port/to_port
+// and icmp codes with icmp_type/icmp_code. Entries are ingress "allow" rules for all protocols by default.
+func toNetworkAclEntry(o lua.Object) (types.NetworkAclEntry, error) {
+	entry := types.NetworkAclEntry{
+		Egress:     aws.Bool(false),
+		Protocol:   aws.String("-1"),
+		RuleAction: types.RuleActionAllow,
+	}
+
+	shorthands := copyWithout(o, "from_port", "to_port", "icmp_type", "icmp_code")
+	if protocol, ok := o["protocol"].(string); ok {
+		if p, found := networkAclProtocols[protocol]; found {
+			shorthands["protocol"] = p
+		}
+	}
+	if from, found := o["from_port"]; found {
+		shorthands["PortRange"] = lua.Object{"From": from, "To": o["to_port"]}
+	}
+	if icmpType, found := o["icmp_type"]; found {
+		shorthands["IcmpTypeCode"] = lua.Object{"Type": icmpType, "Code": o["icmp_code"]}
+	}
+
+	d := decoder{
+		aliases: map[string]string{
+			"cidr":      "CidrBlock",
+			"ipv6_cidr": "Ipv6CidrBlock",
+		},
+	}
+	if err := d.decode(shorthands, &entry); err != nil {
+		return entry, err
+	}
+	if n := aws.ToInt32(entry.RuleNumber); n < 1 || n > maxNetworkAclRuleNumber {
+		return entry, fmt.Errorf("rule_number must be between 1 and %d", maxNetworkAclRuleNumber)
+	}
+	return entry, nil
 }
 
-func fromDescribeIgwOutput(o ec2.DescribeInternetGatewaysOutput) lua.Object {
-	return toLua(o)
+func fromNetworkAclOutput(o networkAclOutput) lua.Object {
+	return normalizeOne(networkAclShape, o.NetworkAcl)
 }
 
-func toCreateNatInput(o lua.Object) ec2.CreateNatGatewayInput {
-	// TODO
-	return ec2.CreateNatGatewayInput{}
+func toDescribeNetworkAclsInput(o lua.Object) (ec2.DescribeNetworkAclsInput, error) {
+	input := ec2.DescribeNetworkAclsInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
 }
 
-func fromCreateNatOutput(o ec2.CreateNatGatewayOutput) lua.Object {
-	return toLua(o)
+func fromDescribeNetworkAclsOutput(o ec2.DescribeNetworkAclsOutput) []lua.Object {
+	return normalizeList(networkAclShape, o.NetworkAcls)
 }
 
-func toDeleteNatInput(o lua.Object) ec2.DeleteNatGatewayInput {
-	// TODO
-	return ec2.DeleteNatGatewayInput{}
+func toDeleteNetworkAclInput(o lua.Object) (ec2.DeleteNetworkAclInput, error) {
+	input := ec2.DeleteNetworkAclInput{}
+	err := decoder{aliases: idAlias("NetworkAclId")}.decode(o, &input)
+	return input, err
 }
 
-func fromDeleteNatOutput(o ec2.DeleteNatGatewayOutput) lua.Object {
+func fromDeleteNetworkAclOutput(o ec2.DeleteNetworkAclOutput) lua.Object {
 	return toLua(o)
 }
 
-func toDescribeNatInput(o lua.Object) ec2.DescribeNatGatewaysInput {
-	// TODO
-	return ec2.DescribeNatGatewaysInput{}
+func toVolumeInput(o lua.Object) (volumeInput, error) {
+	input := volumeInput{}
+	d := decoder{
+		tagResourceType: string(types.ResourceTypeVolume),
+	}
+	if err := d.decode(o, &input); err != nil {
+		return input, err
+	}
+
+	if input.Detach {
+		input.detach = &ec2.DetachVolumeInput{Force: input.Force}
+		return input, nil
+	}
+	if input.InstanceId != "" && input.Device != "" {
+		input.attach = &ec2.AttachVolumeInput{
+			InstanceId: aws.String(input.InstanceId),
+			Device:     aws.String(input.Device),
+		}
+	}
+
+	return input, nil
+}
+
+func fromVolumeOutput(o volumeOutput) lua.Object {
+	return normalizeOne(volumeShape, o.Volume)
 }
 
-func fromDescribeNatOutput(o ec2.DescribeNatGatewaysOutput) lua.Object {
+func toDescribeVolumesInput(o lua.Object) (ec2.DescribeVolumesInput, error) {
+	input := ec2.DescribeVolumesInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
+}
+
+func fromDescribeVolumesOutput(o ec2.DescribeVolumesOutput) []lua.Object {
+	return normalizeList(volumeShape, o.Volumes)
+}
+
+func toDeleteVolumeInput(o lua.Object) (ec2.DeleteVolumeInput, error) {
+	input := ec2.DeleteVolumeInput{}
+	err := decoder{aliases: idAlias("VolumeId")}.decode(o, &input)
+	return input, err
+}
+
+func fromDeleteVolumeOutput(o ec2.DeleteVolumeOutput) lua.Object {
 	return toLua(o)
 }
 
-/**
- Util functions
-**/
-func getObject(o lua.Object, key string) map[string]string {
-	m := o.GetObject(key)
-	obj := make(map[string]string)
-	for k, v := range m {
-		if s, ok := v.(string); ok {
-			obj[k] = s
+// toSnapshotInput returns a copy input if source_snapshot_id is set and a create input otherwise.
+// The source region of a copy defaults to the configured region.
+func toSnapshotInput(config ClientConfiguration) func(o lua.Object) (snapshotInput, error) {
+	return func(o lua.Object) (snapshotInput, error) {
+		d := decoder{
+			tagResourceType: string(types.ResourceTypeSnapshot),
 		}
+
+		_, isCopy := o["source_snapshot_id"]
+		if !isCopy {
+			_, isCopy = o["SourceSnapshotId"]
+		}
+		if isCopy {
+			copyInput := ec2.CopySnapshotInput{}
+			if err := d.decode(o, &copyInput); err != nil {
+				return snapshotInput{}, err
+			}
+			if copyInput.SourceRegion == nil {
+				copyInput.SourceRegion = aws.String(config.Region)
+			}
+			return snapshotInput{copy: &copyInput}, nil
+		}
+
+		createInput := ec2.CreateSnapshotInput{}
+		if err := d.decode(o, &createInput); err != nil {
+			return snapshotInput{}, err
+		}
+		return snapshotInput{create: &createInput}, nil
 	}
-	return obj
 }
 
-func createTags(tags map[string]string) []types.Tag {
-	awsTags := make([]types.Tag, 0, len(tags))
-	for k, v := range tags {
-		awsTags = append(awsTags, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+func fromSnapshotOutput(o snapshotOutput) lua.Object {
+	return normalizeOne(snapshotShape, o.Snapshot)
+}
+
+// toDescribeSnapshotsInput lists only the snapshots owned by the account unless owners or ids are given.
+func toDescribeSnapshotsInput(o lua.Object) (ec2.DescribeSnapshotsInput, error) {
+	input := ec2.DescribeSnapshotsInput{}
+	if err := (decoder{}).decode(o, &input); err != nil {
+		return input, err
+	}
+	if len(input.SnapshotIds) == 0 && len(input.OwnerIds) == 0 {
+		input.OwnerIds = []string{"self"}
 	}
-	return awsTags
+	return input, nil
 }
 
-func createFilters(filters []interface{}) []types.Filter {
-	awsFilters := make([]types.Filter, 0, len(filters))
-	for _, filter := range filters {
-		o, ok := filter.(lua.Object)
-		if !ok {
-			continue
-		}
-		name := o.GetString("Name")
-		values := o.GetList("Values")
-		svalues := make([]string, 0, len(values))
-		for _, v := range values {
-			svalues = append(svalues, v.(string))
-		}
-		awsFilters = append(awsFilters, types.Filter{Name: aws.String(name), Values: svalues})
+func fromDescribeSnapshotsOutput(o ec2.DescribeSnapshotsOutput) []lua.Object {
+	return normalizeList(snapshotShape, o.Snapshots)
+}
+
+func toDeleteSnapshotInput(o lua.Object) (ec2.DeleteSnapshotInput, error) {
+	input := ec2.DeleteSnapshotInput{}
+	err := decoder{aliases: idAlias("SnapshotId")}.decode(o, &input)
+	return input, err
+}
+
+func fromDeleteSnapshotOutput(o ec2.DeleteSnapshotOutput) lua.Object {
+	return toLua(o)
+}
+
+func toDescribeImagesInput(o lua.Object) (imagesInput, error) {
+	input := imagesInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
+}
+
+func fromDescribeImagesOutput(o ec2.DescribeImagesOutput) []lua.Object {
+	return normalizeList(imageShape, o.Images)
+}
+
+func toDescribeAZsInput(o lua.Object) (ec2.DescribeAvailabilityZonesInput, error) {
+	input := ec2.DescribeAvailabilityZonesInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
+}
+
+func fromDescribeAZsOutput(o ec2.DescribeAvailabilityZonesOutput) []lua.Object {
+	return normalizeList(availabilityZoneShape, o.AvailabilityZones)
+}
+
+func toCreateIgwInput(o lua.Object) (ec2.CreateInternetGatewayInput, error) {
+	input := ec2.CreateInternetGatewayInput{}
+	d := decoder{
+		tagResourceType: string(types.ResourceTypeInternetGateway),
 	}
-	return awsFilters
+	err := d.decode(o, &input)
+	return input, err
+}
+
+func fromCreateIgwOutput(o ec2.CreateInternetGatewayOutput) lua.Object {
+	return normalizeOne(internetGatewayShape, o.InternetGateway)
+}
+
+func toDeleteIgwInput(o lua.Object) (ec2.DeleteInternetGatewayInput, error) {
+	input := ec2.DeleteInternetGatewayInput{}
+	err := decoder{aliases: idAlias("InternetGatewayId")}.decode(o, &input)
+	return input, err
+}
+
+func fromDeleteIgwOutput(o ec2.DeleteInternetGatewayOutput) lua.Object {
+	return toLua(o)
+}
+
+func toDescribeIgwInput(o lua.Object) (ec2.DescribeInternetGatewaysInput, error) {
+	input := ec2.DescribeInternetGatewaysInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
+}
+
+func fromDescribeIgwOutput(o ec2.DescribeInternetGatewaysOutput) []lua.Object {
+	return normalizeList(internetGatewayShape, o.InternetGateways)
+}
+
+func toCreateNatInput(o lua.Object) (ec2.CreateNatGatewayInput, error) {
+	input := ec2.CreateNatGatewayInput{}
+	d := decoder{
+		tagResourceType: string(types.ResourceTypeNatgateway),
+	}
+	err := d.decode(o, &input)
+	return input, err
+}
+
+func fromCreateNatOutput(o ec2.CreateNatGatewayOutput) lua.Object {
+	return normalizeOne(natGatewayShape, o.NatGateway)
+}
+
+func toDeleteNatInput(o lua.Object) (ec2.DeleteNatGatewayInput, error) {
+	input := ec2.DeleteNatGatewayInput{}
+	err := decoder{aliases: idAlias("NatGatewayId")}.decode(o, &input)
+	return input, err
+}
+
+func fromDeleteNatOutput(o ec2.DeleteNatGatewayOutput) lua.Object {
+	return toLua(o)
+}
+
+func toDescribeNatInput(o lua.Object) (ec2.DescribeNatGatewaysInput, error) {
+	input := ec2.DescribeNatGatewaysInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
+}
+
+func fromDescribeNatOutput(o ec2.DescribeNatGatewaysOutput) []lua.Object {
+	return normalizeList(natGatewayShape, o.NatGateways)
 }
@@ -0,0 +1,121 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+func TestDiffNetworkAclEntries(t *testing.T) {
+	RegisterTestingT(t)
+
+	entry := func(rule int32, egress bool, protocol string, cidr string) types.NetworkAclEntry {
+		return types.NetworkAclEntry{
+			RuleNumber: aws.Int32(rule),
+			Egress:     aws.Bool(egress),
+			Protocol:   aws.String(protocol),
+			RuleAction: types.RuleActionAllow,
+			CidrBlock:  aws.String(cidr),
+		}
+	}
+
+	current := []types.NetworkAclEntry{
+		entry(100, false, "-1", "10.0.0.0/16"),
+		entry(110, false, "-1", "10.1.0.0/16"),
+		entry(100, true, "-1", "0.0.0.0/0"),
+		entry(defaultNetworkAclRuleNumber, false, "-1", "0.0.0.0/0"),
+		entry(defaultNetworkAclRuleNumber+1, false, "-1", "::/0"),
+		entry(defaultNetworkAclRuleNumber+1, true, "-1", "::/0"),
+	}
+	declared := []types.NetworkAclEntry{
+		entry(100, false, "-1", "10.0.0.0/16"),
+		entry(100, true, "6", "0.0.0.0/0"),
+		entry(120, false, "-1", "10.2.0.0/16"),
+	}
+
+	toCreate, toReplace, toDelete := diffNetworkAclEntries(current, declared)
+	Expect(toCreate).To(HaveLen(1))
+	Expect(*toCreate[0].RuleNumber).To(Equal(int32(120)))
+	Expect(toReplace).To(HaveLen(1))
+	Expect(*toReplace[0].Egress).To(BeTrue())
+	Expect(toDelete).To(HaveLen(1))
+	Expect(*toDelete[0].RuleNumber).To(Equal(int32(110)))
+}
+
+func TestToVpcPeeringInput(t *testing.T) {
+	RegisterTestingT(t)
+
+	config := ClientConfiguration{AccessKey: "key", SecretKey: "secret", Region: "eu-west-1"}
+	toInput := toVpcPeeringInput(config)
+
+	input, err := toInput(lua.Object{
+		"vpc_id":         "vpc-1",
+		"peer_vpc_id":    "vpc-2",
+		"peer_region":    "us-east-1",
+		"auto_accept":    true,
+		"dns_resolution": lua.Object{"requester": true},
+	})
+	Expect(err).To(BeNil())
+	Expect(input.accepter.Region).To(Equal("us-east-1"))
+	Expect(input.accepterCredentials).To(BeFalse())
+	Expect(*input.requesterDnsOpts.AllowDnsResolutionFromRemoteVpc).To(BeTrue())
+
+	input, err = toInput(lua.Object{
+		"vpc_id":      "vpc-1",
+		"peer_vpc_id": "vpc-2",
+		"auto_accept": true,
+		"accepter":    lua.Object{"access_key": "other", "secret_key": "other"},
+	})
+	Expect(err).To(BeNil())
+	Expect(input.accepterCredentials).To(BeTrue())
+	Expect(input.accepter.AccessKey).To(Equal("other"))
+
+	_, err = toInput(lua.Object{"vpc_id": "vpc-1", "peer_vpc_id": "vpc-2", "dns_resolution": lua.Object{"requester": true}})
+	Expect(err).To(MatchError("dns_resolution requires auto_accept"))
+}
+
+func TestToNetworkAclEntry(t *testing.T) {
+	RegisterTestingT(t)
+
+	e, err := toNetworkAclEntry(lua.Object{
+		"rule_number": float64(100),
+		"protocol":    "tcp",
+		"rule_action": "deny",
+		"cidr":        "0.0.0.0/0",
+		"from_port":   float64(22),
+		"to_port":     float64(22),
+	})
+	Expect(err).To(BeNil())
+	Expect(*e.Protocol).To(Equal("6"))
+	Expect(e.RuleAction).To(Equal(types.RuleActionDeny))
+	Expect(*e.Egress).To(BeFalse())
+	Expect(*e.PortRange.From).To(Equal(int32(22)))
+
+	for _, rule := range []float64{0, 32767, 32768} {
+		_, err = toNetworkAclEntry(lua.Object{"rule_number": rule, "cidr": "0.0.0.0/0"})
+		Expect(err).ToNot(BeNil())
+	}
+	_, err = toNetworkAclEntry(lua.Object{"cidr": "0.0.0.0/0"})
+	Expect(err).ToNot(BeNil())
+}
+
+func TestLatestImage(t *testing.T) {
+	RegisterTestingT(t)
+
+	now := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	images := []types.Image{
+		{ImageId: aws.String("ami-old"), CreationDate: aws.String("2023-01-10T10:00:00.000Z")},
+		{ImageId: aws.String("ami-deprecated"), CreationDate: aws.String("2023-07-10T10:00:00.000Z"), DeprecationTime: aws.String("2023-07-20T00:00:00.000Z")},
+		{ImageId: aws.String("ami-latest"), CreationDate: aws.String("2023-06-10T10:00:00.000Z"), DeprecationTime: aws.String("2025-06-10T00:00:00.000Z")},
+	}
+
+	image := latestImage(images, now)
+	Expect(image).ToNot(BeNil())
+	Expect(*image.ImageId).To(Equal("ami-latest"))
+
+	Expect(latestImage(nil, now)).To(BeNil())
+}
@@ -2,49 +2,22 @@ package aws
 
 import (
 	"context"
-	"errors"
-	"fmt"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/tupyy/aws-lua/internal/lua"
 )
 
-func createIamClient(ctx context.Context, accessKey, secretKey, region string) (*iam.Client, error) {
-	optFn := func(opts *awsConfig.LoadOptions) error {
-		opts.Region = region
-		opts.Credentials = getAwsCredentials(ctx, accessKey, secretKey)
-		return nil
-	}
-	awsConfig, err := awsConfig.LoadDefaultConfig(ctx, optFn)
+func createIamClient(ctx context.Context, config ClientConfiguration) (*iam.Client, error) {
+	awsConfig, err := config.load(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create aws config for account: %w", err)
+		return nil, err
 	}
-	return iam.NewFromConfig(awsConfig), nil
-}
-
-func iamGetOpFunc(opType OpType, c ClientConfiguration) opFunc {
-	switch opType {
-	case CreateUser:
-		return createUserFunc(c)
-	case GetUser:
-		return deleteUserFunc(c)
-	case DeleteUser:
-		return deleteAccessKeyFunc(c)
-	case ListUsers:
-		return listAccessKeysFunc(c)
-	case CreateAccessKeys:
-		return createAccessKeyFunc(c)
-	case ListAccessKeys:
-		return listAccessKeysFunc(c)
-	case DeleteAccessKeys:
-		return deleteAccessKeyFunc(c)
-	default:
-		return func(ctx context.Context, input interface{}) (interface{}, error) {
-			return nil, errors.New("unknown op type for iam client")
+	return iam.NewFromConfig(awsConfig, func(o *iam.Options) {
+		// this version of the iam client has no BaseEndpoint option.
+		if endpoint := config.endpoint("iam"); endpoint != "" {
+			o.EndpointResolver = iam.EndpointResolverFromURL(endpoint)
 		}
-	}
+	}), nil
 }
 
 /**
@@ -53,19 +26,22 @@ func iamGetOpFunc(opType OpType, c ClientConfiguration) opFunc {
 func createUserFunc(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(iam.CreateUserInput)
-		iamClient, err := createIamClient(ctx, config.AccessKey, config.SecretKey, config.Region)
+		iamClient, err := createIamClient(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		o, err := iamClient.CreateUser(ctx, &i)
 		if err != nil {
 			return nil, err
 		}
-		output, err := iamClient.CreateUser(ctx, &i)
-		return *output, err
+		return *o, nil
 	}
 }
 
 func getUserFunc(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(iam.GetUserInput)
-		iamClient, err := createIamClient(ctx, config.AccessKey, config.SecretKey, config.Region)
+		iamClient, err := createIamClient(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -80,7 +56,7 @@ func getUserFunc(config ClientConfiguration) opFunc {
 func deleteUserFunc(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(iam.DeleteUserInput)
-		iamClient, err := createIamClient(ctx, config.AccessKey, config.SecretKey, config.Region)
+		iamClient, err := createIamClient(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -95,7 +71,7 @@ func deleteUserFunc(config ClientConfiguration) opFunc {
 func listUserFunc(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(iam.ListUsersInput)
-		iamClient, err := createIamClient(ctx, config.AccessKey, config.SecretKey, config.Region)
+		iamClient, err := createIamClient(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -114,7 +90,7 @@ func listUserFunc(config ClientConfiguration) opFunc {
 func createAccessKeyFunc(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(iam.CreateAccessKeyInput)
-		iamClient, err := createIamClient(ctx, config.AccessKey, config.SecretKey, config.Region)
+		iamClient, err := createIamClient(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -129,7 +105,7 @@ func createAccessKeyFunc(config ClientConfiguration) opFunc {
 func listAccessKeysFunc(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(iam.ListAccessKeysInput)
-		iamClient, err := createIamClient(ctx, config.AccessKey, config.SecretKey, config.Region)
+		iamClient, err := createIamClient(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -144,7 +120,7 @@ func listAccessKeysFunc(config ClientConfiguration) opFunc {
 func deleteAccessKeyFunc(config ClientConfiguration) opFunc {
 	return func(ctx context.Context, input interface{}) (interface{}, error) {
 		i := input.(iam.DeleteAccessKeyInput)
-		iamClient, err := createIamClient(ctx, config.AccessKey, config.SecretKey, config.Region)
+		iamClient, err := createIamClient(ctx, config)
 		if err != nil {
 			return nil, err
 		}
@@ -160,76 +136,94 @@ func deleteAccessKeyFunc(config ClientConfiguration) opFunc {
 	Transformation function
 **/
 
-func toCreateUserInput(o lua.Object) iam.CreateUserInput {
-	username := o.GetString("username")
-	if username == "" {
-		return iam.CreateUserInput{}
+// userAliases keeps the legacy "username" key. The id of a user is its name.
+var userAliases = map[string]string{"username": "UserName", "id": "UserName"}
+
+var (
+	userSchema = &schema{
+		fields: map[string]field{
+			"user_name":            {kind: stringKind, required: true, aliases: []string{"username"}},
+			"path":                 {kind: stringKind},
+			"permissions_boundary": {kind: stringKind, format: arnFormat},
+		},
 	}
-	return iam.CreateUserInput{UserName: aws.String(username)}
+
+	accessKeySchema = &schema{
+		fields: map[string]field{
+			"user_name": {kind: stringKind, aliases: []string{"username"}},
+		},
+	}
+)
+
+func toCreateUserInput(o lua.Object) (iam.CreateUserInput, error) {
+	input := iam.CreateUserInput{}
+	err := decoder{aliases: userAliases}.decode(o, &input)
+	return input, err
 }
 
 func fromCreateUserOutput(o iam.CreateUserOutput) lua.Object {
-	if o.User == nil {
-		return lua.Object{}
-	}
-	return lua.Object{
-		"username": o.User.UserName,
-		"arn":      o.User.Arn,
-	}
+	return normalizeOne(userShape, o.User)
 }
 
-func toListUserInput(o lua.Object) iam.ListUsersInput {
-	return iam.ListUsersInput{}
+func toListUserInput(o lua.Object) (iam.ListUsersInput, error) {
+	input := iam.ListUsersInput{}
+	err := decoder{}.decode(o, &input)
+	return input, err
 }
 
-func fromListUserOutput(o iam.ListUsersOutput) lua.Object {
-	return toLua(o)
+func fromListUserOutput(o iam.ListUsersOutput) []lua.Object {
+	return normalizeList(userShape, o.Users)
 }
 
-func toGetUserInput(o lua.Object) iam.GetUserInput {
-	username := o.GetString("username")
-	if username == "" {
-		return iam.GetUserInput{}
-	}
-	return iam.GetUserInput{
-		UserName: aws.String(username),
-	}
+func toGetUserInput(o lua.Object) (iam.GetUserInput, error) {
+	input := iam.GetUserInput{}
+	err := decoder{aliases: userAliases}.decode(o, &input)
+	return input, err
 }
 
 func fromGetUserOutput(o iam.GetUserOutput) lua.Object {
-	if o.User == nil {
-		return lua.Object{}
-	}
-	l := lua.Object{
-		"username": o.User.UserName,
-		"arn":      o.User.Arn,
-	}
-	if len(o.User.Tags) > 0 {
-		tags := make(map[string]string)
-		for _, t := range o.User.Tags {
-			tags[*t.Key] = *t.Value
-		}
-		l["tags"] = tags
-	}
-	return l
+	return normalizeOne(userShape, o.User)
 }
 
-func toCreateAccessKeyInput(o lua.Object) iam.CreateAccessKeyInput {
-	username := o.GetString("username")
-	if username == "" {
-		return iam.CreateAccessKeyInput{}
-	}
-	return iam.CreateAccessKeyInput{
-		UserName: aws.String(username),
-	}
+func toCreateAccessKeyInput(o lua.Object) (iam.CreateAccessKeyInput, error) {
+	input := iam.CreateAccessKeyInput{}
+	err := decoder{aliases: userAliases}.decode(o, &input)
+	return input, err
 }
 
 func fromCreateAccessKeyOutput(o iam.CreateAccessKeyOutput) lua.Object {
-	if o.AccessKey == nil {
-		return lua.Object{}
-	}
-	return lua.Object{
-		"access_key":        o.AccessKey.AccessKeyId,
-		"secret_access_key": o.AccessKey.SecretAccessKey,
+	return normalizeOne(accessKeyShape, o.AccessKey)
+}
+
+func toDeleteUserInput(o lua.Object) (iam.DeleteUserInput, error) {
+	input := iam.DeleteUserInput{}
+	err := decoder{aliases: userAliases}.decode(o, &input)
+	return input, err
+}
+
+func fromDeleteUserOutput(o iam.DeleteUserOutput) lua.Object {
+	return toLua(o)
+}
+
+func toListAccessKeysInput(o lua.Object) (iam.ListAccessKeysInput, error) {
+	input := iam.ListAccessKeysInput{}
+	err := decoder{aliases: userAliases}.decode(o, &input)
+	return input, err
+}
+
+func fromListAccessKeysOutput(o iam.ListAccessKeysOutput) []lua.Object {
+	return normalizeList(accessKeyShape, o.AccessKeyMetadata)
+}
+
+func toDeleteAccessKeyInput(o lua.Object) (iam.DeleteAccessKeyInput, error) {
+	input := iam.DeleteAccessKeyInput{}
+	d := decoder{
+		aliases: map[string]string{"username": "UserName", "id": "AccessKeyId"},
 	}
+	err := d.decode(o, &input)
+	return input, err
+}
+
+func fromDeleteAccessKeyOutput(o iam.DeleteAccessKeyOutput) lua.Object {
+	return toLua(o)
 }
@@ -0,0 +1,118 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+// shape describes where the id, the arn and the state of a resource are found in its sdk struct.
+// Paths are dot separated field names (e.g. "Status.Code"). Empty paths are not set.
+type shape struct {
+	id    string
+	arn   string
+	state string
+}
+
+var (
+	vpcShape              = shape{id: "VpcId", state: "State"}
+	subnetShape           = shape{id: "SubnetId", arn: "SubnetArn", state: "State"}
+	vpcEndpointShape      = shape{id: "VpcEndpointId", state: "State"}
+	vpcPeeringShape       = shape{id: "VpcPeeringConnectionId", state: "Status.Code"}
+	networkAclShape       = shape{id: "NetworkAclId"}
+	volumeShape           = shape{id: "VolumeId", state: "State"}
+	snapshotShape         = shape{id: "SnapshotId", state: "State"}
+	imageShape            = shape{id: "ImageId", state: "State"}
+	availabilityZoneShape = shape{id: "ZoneId", state: "State"}
+	internetGatewayShape  = shape{id: "InternetGatewayId"}
+	natGatewayShape       = shape{id: "NatGatewayId", state: "State"}
+	userShape             = shape{id: "UserName", arn: "Arn"}
+	accessKeyShape        = shape{id: "AccessKeyId", state: "Status"}
+)
+
+// normalize returns the normalized representation of a sdk resource struct:
+//
+//	{id = ..., arn = ..., state = ..., tags = {k = v}, <attributes in snake_case>, raw = <untouched sdk struct>}
+func (s shape) normalize(resource any) lua.Object {
+	raw := toLua(resource)
+
+	o := snakeCaseKeys(raw)
+	o["tags"] = tagMap(raw.GetList("Tags"))
+	if id, found := lookup(raw, s.id); found {
+		o["id"] = id
+	}
+	if arn, found := lookup(raw, s.arn); found {
+		o["arn"] = arn
+	}
+	if state, found := lookup(raw, s.state); found {
+		o["state"] = state
+	}
+	o["raw"] = raw
+
+	return o
+}
+
+// normalizeList normalizes every resource of the list.
+func normalizeList[T any](s shape, resources []T) []lua.Object {
+	list := make([]lua.Object, 0, len(resources))
+	for _, r := range resources {
+		list = append(list, s.normalize(r))
+	}
+	return list
+}
+
+// normalizeOne normalizes the resource or returns an empty object if there is none.
+func normalizeOne[T any](s shape, resource *T) lua.Object {
+	if resource == nil {
+		return lua.Object{}
+	}
+	return s.normalize(*resource)
+}
+
+// snakeCaseKeys returns a copy of the object with every key, nested ones included, in snake_case.
+func snakeCaseKeys(o lua.Object) lua.Object {
+	c := make(lua.Object, len(o))
+	for k, v := range o {
+		c[toSnakeCase(k)] = snakeCaseValue(v)
+	}
+	return c
+}
+
+func snakeCaseValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case lua.Object:
+		return snakeCaseKeys(val)
+	case []interface{}:
+		list := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			list = append(list, snakeCaseValue(item))
+		}
+		return list
+	}
+	return v
+}
+
+// tagMap converts a list of {Key, Value} objects into a map.
+func tagMap(tags []interface{}) lua.Object {
+	m := lua.Object{}
+	for _, t := range tags {
+		tag, ok := t.(lua.Object)
+		if !ok {
+			continue
+		}
+		m[tag.GetString("Key")] = tag.GetString("Value")
+	}
+	return m
+}
+
+func lookup(o lua.Object, path string) (interface{}, bool) {
+	if path == "" {
+		return nil, false
+	}
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		o = lua.Object(o.GetObject(p))
+	}
+	v, found := o[parts[len(parts)-1]]
+	return v, found
+}
@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+func TestNormalize(t *testing.T) {
+	RegisterTestingT(t)
+
+	vpc := types.Vpc{
+		VpcId:     aws.String("vpc-1"),
+		CidrBlock: aws.String("10.0.0.0/16"),
+		State:     types.VpcStateAvailable,
+		Tags:      []types.Tag{{Key: aws.String("myvpc"), Value: aws.String("true")}},
+		CidrBlockAssociationSet: []types.VpcCidrBlockAssociation{
+			{AssociationId: aws.String("assoc-1"), CidrBlock: aws.String("10.0.0.0/16")},
+		},
+	}
+
+	o := vpcShape.normalize(vpc)
+	Expect(o["id"]).To(Equal("vpc-1"))
+	Expect(o["state"]).To(Equal("available"))
+	Expect(o["cidr_block"]).To(Equal("10.0.0.0/16"))
+	Expect(o["tags"]).To(Equal(lua.Object{"myvpc": "true"}))
+	Expect(o).ToNot(HaveKey("arn"))
+	Expect(o["cidr_block_association_set"]).To(Equal([]interface{}{
+		lua.Object{"association_id": "assoc-1", "cidr_block": "10.0.0.0/16"},
+	}))
+
+	raw := o.GetObject("raw")
+	Expect(raw["VpcId"]).To(Equal("vpc-1"))
+	Expect(raw).To(HaveKey("Tags"))
+}
+
+func TestNormalizeShapes(t *testing.T) {
+	RegisterTestingT(t)
+
+	peering := vpcPeeringShape.normalize(types.VpcPeeringConnection{
+		VpcPeeringConnectionId: aws.String("pcx-1"),
+		Status:                 &types.VpcPeeringConnectionStateReason{Code: types.VpcPeeringConnectionStateReasonCodeActive},
+	})
+	Expect(peering["id"]).To(Equal("pcx-1"))
+	Expect(peering["state"]).To(Equal("active"))
+
+	subnet := subnetShape.normalize(types.Subnet{SubnetId: aws.String("subnet-1"), SubnetArn: aws.String("arn:subnet")})
+	Expect(subnet["arn"]).To(Equal("arn:subnet"))
+	Expect(subnet["tags"]).To(Equal(lua.Object{}))
+
+	user := userShape.normalize(iamtypes.User{
+		UserName: aws.String("bob"),
+		Arn:      aws.String("arn:aws:iam::123456789012:user/bob"),
+		Tags:     []iamtypes.Tag{{Key: aws.String("team"), Value: aws.String("infra")}},
+	})
+	Expect(user["id"]).To(Equal("bob"))
+	Expect(user["arn"]).To(Equal("arn:aws:iam::123456789012:user/bob"))
+	Expect(user["tags"]).To(Equal(lua.Object{"team": "infra"}))
+}
+
+func TestNormalizeList(t *testing.T) {
+	RegisterTestingT(t)
+
+	list := fromDescribeVpcsOutput(ec2.DescribeVpcsOutput{
+		Vpcs: []types.Vpc{{VpcId: aws.String("vpc-1")}, {VpcId: aws.String("vpc-2")}},
+	})
+	Expect(list).To(HaveLen(2))
+	Expect(list[1]["id"]).To(Equal("vpc-2"))
+
+	Expect(fromDescribeVpcsOutput(ec2.DescribeVpcsOutput{})).To(BeEmpty())
+	Expect(fromCreateVpcOutput(ec2.CreateVpcOutput{})).To(Equal(lua.Object{}))
+}
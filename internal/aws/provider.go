@@ -3,127 +3,192 @@ package aws
 import (
 	"context"
 	"fmt"
+	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/tupyy/aws-lua/internal/lua"
 )
 
 type AwsProvider struct {
-	config ClientConfiguration
+	config   ClientConfiguration
+	registry *registry
 }
 
 func New(c ClientConfiguration) *AwsProvider {
-	return &AwsProvider{c}
+	return newProvider(c, builtinTypes(c))
+}
+
+// Handlers implement the verbs of a built-in resource type in place of the aws api.
+type Handlers struct {
+	Create lua.ResourceHandler
+	Get    lua.ResourceHandler
+	List   func(ctx context.Context, o lua.Object) ([]lua.Object, error)
+	Update lua.ResourceHandler
+	Delete lua.ResourceHandler
+}
+
+// NewWithHandlers returns a provider whose built-in resource types are implemented by the handlers, e.g. in memory.
+// The types keep their schemas and dependencies, and a verb is only supported when aws supports it too:
+// the built-in types missing from the handlers support no verb. Call and the tag functions still call aws.
+func NewWithHandlers(handlers map[string]Handlers) *AwsProvider {
+	types := builtinTypes(ClientConfiguration{})
+	for i, t := range types {
+		types[i] = handlers[t.name].replace(t)
+	}
+	return newProvider(ClientConfiguration{}, types)
+}
+
+func (h Handlers) replace(t resourceType) resourceType {
+	if t.create != nil {
+		t.create = h.Create
+	}
+	if t.get != nil {
+		t.get = h.Get
+	}
+	if t.list != nil {
+		t.list = h.List
+	}
+	if t.update != nil {
+		t.update = h.Update
+	}
+	if t.delete != nil {
+		t.delete = h.Delete
+	}
+	return t
+}
+
+func newProvider(c ClientConfiguration, types []resourceType) *AwsProvider {
+	r := newRegistry()
+	for _, t := range types {
+		if err := r.register(t); err != nil {
+			panic(err)
+		}
+	}
+	return &AwsProvider{config: c, registry: r}
 }
 
 func (a *AwsProvider) Create(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
-	var opFunc func(ctx context.Context, o lua.Object) (lua.Object, error)
-
-	switch resource {
-	case lua.AwsUser:
-		opFunc = NewBuilder[iam.CreateUserInput, iam.CreateUserOutput](a.config).
-			Type(IamClient).
-			Op(CreateUser).
-			TransformInputFunc(toCreateUserInput).
-			TransformOutputFunc(fromCreateUserOutput).
-			Build(ctx)
-	case lua.AwsAccessKey:
-		opFunc = NewBuilder[iam.CreateAccessKeyInput, iam.CreateAccessKeyOutput](a.config).
-			Type(IamClient).
-			Op(CreateAccessKeys).
-			TransformInputFunc(toCreateAccessKeyInput).
-			TransformOutputFunc(fromCreateAccessKeyOutput).
-			Build(ctx)
-	case lua.AwsSubnet:
-		opFunc = NewBuilder[ec2.CreateSubnetInput, ec2.CreateSubnetOutput](a.config).
-			Type(Ec2Client).
-			Op(CreateSubnet).
-			TransformInputFunc(toCreateSubnetInput).
-			TransformOutputFunc(fromCreateSubnetOutput).
-			Build(ctx)
-	case lua.AwsVpc:
-		opFunc = NewBuilder[ec2.CreateVpcInput, ec2.CreateVpcOutput](a.config).
-			Type(Ec2Client).
-			Op(CreateVpc).
-			TransformInputFunc(toCreateVpcInput).
-			TransformOutputFunc(fromCreateVpcOutput).
-			Build(ctx)
-	case lua.AwsInternetGateway:
-		opFunc = NewBuilder[ec2.CreateInternetGatewayInput, ec2.CreateInternetGatewayOutput](a.config).
-			Type(Ec2Client).
-			Op(CreateIgw).
-			TransformInputFunc(toCreateIgwInput).
-			TransformOutputFunc(fromCreateIgwOutput).
-			Build(ctx)
-	case lua.AwsNatGateway:
-		opFunc = NewBuilder[ec2.CreateNatGatewayInput, ec2.CreateNatGatewayOutput](a.config).
-			Type(Ec2Client).
-			Op(CreateNat).
-			TransformInputFunc(toCreateNatInput).
-			TransformOutputFunc(fromCreateNatOutput).
-			Build(ctx)
-
-	default:
-		return lua.Object{}, fmt.Errorf("unknown resource")
-	}
-
-	return opFunc(ctx, o)
+	t, err := a.registry.lookup(resource)
+	if err != nil {
+		return lua.Object{}, err
+	}
+	if t.create == nil {
+		return lua.Object{}, unsupportedVerb(resource, "create")
+	}
+	if err := t.schema.validate(o); err != nil {
+		return lua.Object{}, fmt.Errorf("invalid input: %w", err)
+	}
+	return t.create(ctx, o)
+}
+
+// Get returns the resource {id = ...}.
+func (a *AwsProvider) Get(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	t, err := a.registry.lookup(resource)
+	if err != nil {
+		return lua.Object{}, err
+	}
+	if t.get == nil {
+		return lua.Object{}, unsupportedVerb(resource, "get")
+	}
+	return t.get(ctx, o)
+}
+
+func (a *AwsProvider) Update(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	t, err := a.registry.lookup(resource)
+	if err != nil {
+		return lua.Object{}, err
+	}
+	if t.update == nil {
+		return lua.Object{}, unsupportedVerb(resource, "update")
+	}
+	if err := t.updateSchema.validate(o); err != nil {
+		return lua.Object{}, fmt.Errorf("invalid input: %w", err)
+	}
+	return t.update(ctx, o)
 }
 
 func (a *AwsProvider) Delete(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
-	return lua.Object{}, fmt.Errorf("unknown resource")
+	t, err := a.registry.lookup(resource)
+	if err != nil {
+		return lua.Object{}, err
+	}
+	if t.delete == nil {
+		return lua.Object{}, unsupportedVerb(resource, "delete")
+	}
+	return t.delete(ctx, o)
 }
 
-func (a *AwsProvider) List(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
-	var opFunc func(ctx context.Context, o lua.Object) (lua.Object, error)
-	switch resource {
-	case lua.AwsUser:
-		opFunc = NewBuilder[iam.ListUsersInput, iam.ListUsersOutput](a.config).
-			Type(IamClient).
-			Op(ListUsers).
-			TransformInputFunc(toListUserInput).
-			TransformOutputFunc(fromListUserOutput).
-			Build(ctx)
-	case lua.AwsVpc:
-		opFunc = NewBuilder[ec2.DescribeVpcsInput, ec2.DescribeVpcsOutput](a.config).
-			Type(Ec2Client).
-			Op(ListVpcs).
-			TransformInputFunc(toDescribeVpcsInput).
-			TransformOutputFunc(fromDescribeVpcsOutput).
-			Build(ctx)
-	case lua.AwsSubnet:
-		opFunc = NewBuilder[ec2.DescribeSubnetsInput, ec2.DescribeSubnetsOutput](a.config).
-			Type(Ec2Client).
-			Op(ListSubnets).
-			TransformInputFunc(toDescribeSubnetsInput).
-			TransformOutputFunc(fromDescribeSubnetsOutput).
-			Build(ctx)
-	case lua.AwsAZs:
-		opFunc = NewBuilder[ec2.DescribeAvailabilityZonesInput, ec2.DescribeAvailabilityZonesOutput](a.config).
-			Type(Ec2Client).
-			Op(ListAvailabilityZones).
-			TransformInputFunc(toDescribeAZsInput).
-			TransformOutputFunc(fromDescribeAZsOutput).
-			Build(ctx)
-	case lua.AwsInternetGateway:
-		opFunc = NewBuilder[ec2.DescribeInternetGatewaysInput, ec2.DescribeInternetGatewaysOutput](a.config).
-			Type(Ec2Client).
-			Op(ListIgws).
-			TransformInputFunc(toDescribeIgwInput).
-			TransformOutputFunc(fromDescribeIgwOutput).
-			Build(ctx)
-	case lua.AwsNatGateway:
-		opFunc = NewBuilder[ec2.DescribeNatGatewaysInput, ec2.DescribeNatGatewaysOutput](a.config).
-			Type(Ec2Client).
-			Op(ListNats).
-			TransformInputFunc(toDescribeNatInput).
-			TransformOutputFunc(fromDescribeNatOutput).
-			Build(ctx)
-	default:
-		return lua.Object{}, fmt.Errorf("unknown resource")
-	}
-
-	return opFunc(ctx, o)
+// List returns the normalized resources matching the input.
+func (a *AwsProvider) List(ctx context.Context, resource string, o lua.Object) ([]lua.Object, error) {
+	t, err := a.registry.lookup(resource)
+	if err != nil {
+		return nil, err
+	}
+	if t.list == nil {
+		return nil, unsupportedVerb(resource, "list")
+	}
+	return t.list(ctx, o)
+}
 
+// Define registers a resource type implemented outside of the provider.
+// The type can then be used like a built-in one. Built-in types cannot be redefined.
+// The schema validates the inputs of create and update.
+func (a *AwsProvider) Define(def lua.ResourceDefinition) error {
+	s, err := parseSchema(def.Schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema of %q: %w", def.Name, err)
+	}
+	return a.registry.register(resourceType{
+		name:         def.Name,
+		create:       def.Create,
+		get:          def.Read,
+		list:         def.List,
+		update:       def.Update,
+		delete:       def.Delete,
+		schema:       s,
+		updateSchema: s,
+		dependsOn:    def.DependsOn,
+	})
+}
+
+// Types returns the registered resource types: {name, verbs, depends_on}.
+func (a *AwsProvider) Types() []lua.Object {
+	all := a.registry.all()
+	types := make([]lua.Object, 0, len(all))
+	for _, t := range all {
+		dependsOn := t.dependsOn
+		if dependsOn == nil {
+			dependsOn = []string{}
+		}
+		types = append(types, lua.Object{
+			"name":       t.name,
+			"verbs":      t.verbs(),
+			"depends_on": dependsOn,
+		})
+	}
+	return types
+}
+
+// TagType returns the ec2 resource type of the tags of a registered resource type, or "" when it has none.
+func (a *AwsProvider) TagType(resource string) string {
+	t, err := a.registry.lookup(resource)
+	if err != nil {
+		return ""
+	}
+	return t.tagType
+}
+
+// ResourceType returns the resource type of an id accepted by Tag and Untag: the ec2 id of a registered type,
+// or the ARN of an iam user (aws_user) or role (aws_role).
+func (a *AwsProvider) ResourceType(id string) (string, error) {
+	if strings.HasPrefix(id, "arn:") {
+		kind, _, err := parseIamArn(id)
+		if err != nil {
+			return "", err
+		}
+		return "aws_" + kind, nil
+	}
+	if name, found := a.registry.byId(id); found {
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown resource type of %q", id)
 }
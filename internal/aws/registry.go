@@ -0,0 +1,139 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+type resourceHandler = func(ctx context.Context, o lua.Object) (lua.Object, error)
+
+type listHandler = func(ctx context.Context, o lua.Object) ([]lua.Object, error)
+
+// resourceType declares a resource type in one place: its handlers, the schemas of its inputs and
+// the resource types it depends on. Verbs without a handler are not supported by the type.
+type resourceType struct {
+	name   string
+	create resourceHandler
+	get    resourceHandler
+	list   listHandler
+	update resourceHandler
+	delete resourceHandler
+	// schema validates the create input and updateSchema the update input.
+	schema       *schema
+	updateSchema *schema
+	// dependsOn lists the resource types which must exist before this one.
+	dependsOn []string
+	// tagType is the ec2 resource type used by the tag api.
+	tagType string
+	// idPrefix is the prefix of the ec2 ids of the resources, e.g. "vpc-".
+	idPrefix string
+}
+
+// verbs returns the verbs supported by the resource type.
+func (t resourceType) verbs() []string {
+	verbs := []string{}
+	if t.create != nil {
+		verbs = append(verbs, "create")
+	}
+	if t.get != nil {
+		verbs = append(verbs, "get")
+	}
+	if t.list != nil {
+		verbs = append(verbs, "list")
+	}
+	if t.update != nil {
+		verbs = append(verbs, "update")
+	}
+	if t.delete != nil {
+		verbs = append(verbs, "delete")
+	}
+	return verbs
+}
+
+// registry holds the resource types by name.
+type registry struct {
+	types map[string]resourceType
+}
+
+func newRegistry() *registry {
+	return &registry{types: make(map[string]resourceType)}
+}
+
+func (r *registry) register(t resourceType) error {
+	if t.name == "" {
+		return errors.New("resource type without name")
+	}
+	if _, found := r.types[t.name]; found {
+		return fmt.Errorf("resource type %q already registered", t.name)
+	}
+	r.types[t.name] = t
+	return nil
+}
+
+func (r *registry) lookup(name string) (resourceType, error) {
+	t, found := r.types[name]
+	if !found {
+		return resourceType{}, fmt.Errorf("unknown resource %q", name)
+	}
+	return t, nil
+}
+
+// all returns the resource types sorted by name.
+func (r *registry) all() []resourceType {
+	types := make([]resourceType, 0, len(r.types))
+	for _, t := range r.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].name < types[j].name })
+	return types
+}
+
+// byTagType returns the name of the resource type registered for the ec2 tag resource type.
+func (r *registry) byTagType(tagType string) (string, bool) {
+	for _, t := range r.types {
+		if t.tagType != "" && t.tagType == tagType {
+			return t.name, true
+		}
+	}
+	return "", false
+}
+
+// byId returns the name of the resource type whose ids have the prefix of the ec2 id.
+func (r *registry) byId(id string) (string, bool) {
+	for _, t := range r.types {
+		if t.idPrefix != "" && strings.HasPrefix(id, t.idPrefix) {
+			return t.name, true
+		}
+	}
+	return "", false
+}
+
+// getFromList returns a get handler looking for the resource {id = ...} with the list handler.
+// idsField is the field of the list input filtering by ids.
+func getFromList(list listHandler, idsField string) resourceHandler {
+	return func(ctx context.Context, o lua.Object) (lua.Object, error) {
+		id := o.GetString("id")
+		if id == "" {
+			return lua.Object{}, errors.New(`field "id" is required`)
+		}
+		items, err := list(ctx, lua.Object{idsField: []interface{}{id}})
+		if err != nil {
+			return lua.Object{}, err
+		}
+		for _, item := range items {
+			if item["id"] == id {
+				return item, nil
+			}
+		}
+		return lua.Object{}, fmt.Errorf("resource %q not found", id)
+	}
+}
+
+func unsupportedVerb(resource, verb string) error {
+	return fmt.Errorf("resource %q does not support %s", resource, verb)
+}
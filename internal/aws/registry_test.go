@@ -0,0 +1,81 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+func TestRegistry(t *testing.T) {
+	RegisterTestingT(t)
+
+	r := newRegistry()
+	Expect(r.register(resourceType{name: "b"})).To(Succeed())
+	Expect(r.register(resourceType{name: "a", tagType: "vpc"})).To(Succeed())
+	Expect(r.register(resourceType{name: "a"})).ToNot(Succeed())
+	Expect(r.register(resourceType{})).ToNot(Succeed())
+
+	_, err := r.lookup("c")
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(Equal(`unknown resource "c"`))
+
+	all := r.all()
+	Expect(all).To(HaveLen(2))
+	Expect(all[0].name).To(Equal("a"))
+
+	name, found := r.byTagType("vpc")
+	Expect(found).To(BeTrue())
+	Expect(name).To(Equal("a"))
+}
+
+func TestGetFromList(t *testing.T) {
+	RegisterTestingT(t)
+
+	var input lua.Object
+	list := func(ctx context.Context, o lua.Object) ([]lua.Object, error) {
+		input = o
+		return []lua.Object{{"id": "vpc-1"}}, nil
+	}
+
+	get := getFromList(list, "VpcIds")
+	o, err := get(context.TODO(), lua.Object{"id": "vpc-1"})
+	Expect(err).To(BeNil())
+	Expect(o["id"]).To(Equal("vpc-1"))
+	Expect(input).To(Equal(lua.Object{"VpcIds": []interface{}{"vpc-1"}}))
+
+	_, err = get(context.TODO(), lua.Object{"id": "vpc-2"})
+	Expect(err).ToNot(BeNil())
+
+	_, err = get(context.TODO(), lua.Object{})
+	Expect(err).ToNot(BeNil())
+}
+
+func TestProviderDispatch(t *testing.T) {
+	RegisterTestingT(t)
+
+	a := New(ClientConfiguration{})
+
+	_, err := a.Create(context.TODO(), "aws_image", lua.Object{})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(Equal(`resource "aws_image" does not support create`))
+
+	_, err = a.Create(context.TODO(), "aws_subnet", lua.Object{"cidr": "10.0.1.0/24"})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(Equal(`invalid input: field "vpc_id" is required`))
+
+	_, err = a.List(context.TODO(), "aws_unknown", lua.Object{})
+	Expect(err).ToNot(BeNil())
+
+	types := a.Types()
+	Expect(types).ToNot(BeEmpty())
+	for _, typ := range types {
+		if typ["name"] == "aws_user" {
+			Expect(typ["verbs"]).To(Equal([]string{"create", "get", "list", "delete"}))
+		}
+		if typ["name"] == "aws_subnet" {
+			Expect(typ["depends_on"]).To(Equal([]string{"aws_vpc"}))
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// builtinTypes returns the resource types implemented by the provider.
+func builtinTypes(c ClientConfiguration) []resourceType {
+	listVpcsHandler := NewBuilder[ec2.DescribeVpcsInput, ec2.DescribeVpcsOutput](c).
+		Op(listVpcs).
+		TransformInputFunc(toDescribeVpcsInput).
+		TransformListOutputFunc(fromDescribeVpcsOutput).
+		BuildList()
+	listSubnetsHandler := NewBuilder[ec2.DescribeSubnetsInput, ec2.DescribeSubnetsOutput](c).
+		Op(listSubnets).
+		TransformInputFunc(toDescribeSubnetsInput).
+		TransformListOutputFunc(fromDescribeSubnetsOutput).
+		BuildList()
+	listVpcEndpointsHandler := NewBuilder[ec2.DescribeVpcEndpointsInput, ec2.DescribeVpcEndpointsOutput](c).
+		Op(listVpcEndpoints).
+		TransformInputFunc(toDescribeVpcEndpointsInput).
+		TransformListOutputFunc(fromDescribeVpcEndpointsOutput).
+		BuildList()
+	listVpcPeeringsHandler := NewBuilder[ec2.DescribeVpcPeeringConnectionsInput, ec2.DescribeVpcPeeringConnectionsOutput](c).
+		Op(listVpcPeerings).
+		TransformInputFunc(toDescribeVpcPeeringsInput).
+		TransformListOutputFunc(fromDescribeVpcPeeringsOutput).
+		BuildList()
+	listNetworkAclsHandler := NewBuilder[ec2.DescribeNetworkAclsInput, ec2.DescribeNetworkAclsOutput](c).
+		Op(listNetworkAcls).
+		TransformInputFunc(toDescribeNetworkAclsInput).
+		TransformListOutputFunc(fromDescribeNetworkAclsOutput).
+		BuildList()
+	listVolumesHandler := NewBuilder[ec2.DescribeVolumesInput, ec2.DescribeVolumesOutput](c).
+		Op(listVolumes).
+		TransformInputFunc(toDescribeVolumesInput).
+		TransformListOutputFunc(fromDescribeVolumesOutput).
+		BuildList()
+	listSnapshotsHandler := NewBuilder[ec2.DescribeSnapshotsInput, ec2.DescribeSnapshotsOutput](c).
+		Op(listSnapshots).
+		TransformInputFunc(toDescribeSnapshotsInput).
+		TransformListOutputFunc(fromDescribeSnapshotsOutput).
+		BuildList()
+	listImagesHandler := NewBuilder[imagesInput, ec2.DescribeImagesOutput](c).
+		Op(listImages).
+		TransformInputFunc(toDescribeImagesInput).
+		TransformListOutputFunc(fromDescribeImagesOutput).
+		BuildList()
+	listAZsHandler := NewBuilder[ec2.DescribeAvailabilityZonesInput, ec2.DescribeAvailabilityZonesOutput](c).
+		Op(listAZs).
+		TransformInputFunc(toDescribeAZsInput).
+		TransformListOutputFunc(fromDescribeAZsOutput).
+		BuildList()
+	listIgwsHandler := NewBuilder[ec2.DescribeInternetGatewaysInput, ec2.DescribeInternetGatewaysOutput](c).
+		Op(listIgws).
+		TransformInputFunc(toDescribeIgwInput).
+		TransformListOutputFunc(fromDescribeIgwOutput).
+		BuildList()
+	listNatsHandler := NewBuilder[ec2.DescribeNatGatewaysInput, ec2.DescribeNatGatewaysOutput](c).
+		Op(listNats).
+		TransformInputFunc(toDescribeNatInput).
+		TransformListOutputFunc(fromDescribeNatOutput).
+		BuildList()
+
+	return []resourceType{
+		{
+			name: "aws_vpc",
+			create: NewBuilder[ec2.CreateVpcInput, ec2.CreateVpcOutput](c).
+				Op(createVpc).
+				TransformInputFunc(toCreateVpcInput).
+				TransformOutputFunc(fromCreateVpcOutput).
+				Build(),
+			get:  getFromList(listVpcsHandler, "VpcIds"),
+			list: listVpcsHandler,
+			delete: NewBuilder[ec2.DeleteVpcInput, ec2.DeleteVpcOutput](c).
+				Op(deleteVpc).
+				TransformInputFunc(toDeleteVpcInput).
+				TransformOutputFunc(fromDeleteVpcOutput).
+				Build(),
+			schema:   vpcSchema,
+			tagType:  string(types.ResourceTypeVpc),
+			idPrefix: "vpc-",
+		},
+		{
+			name: "aws_subnet",
+			create: NewBuilder[ec2.CreateSubnetInput, ec2.CreateSubnetOutput](c).
+				Op(createSubnet).
+				TransformInputFunc(toCreateSubnetInput).
+				TransformOutputFunc(fromCreateSubnetOutput).
+				Build(),
+			get:  getFromList(listSubnetsHandler, "SubnetIds"),
+			list: listSubnetsHandler,
+			delete: NewBuilder[ec2.DeleteSubnetInput, ec2.DeleteSubnetOutput](c).
+				Op(deleteSubnet).
+				TransformInputFunc(toDeleteSubnetInput).
+				TransformOutputFunc(fromDeleteSubnetOutput).
+				Build(),
+			schema:    subnetSchema,
+			dependsOn: []string{"aws_vpc"},
+			tagType:   string(types.ResourceTypeSubnet),
+			idPrefix:  "subnet-",
+		},
+		{
+			name: "aws_vpc_endpoint",
+			create: NewBuilder[ec2.CreateVpcEndpointInput, ec2.CreateVpcEndpointOutput](c).
+				Op(createVpcEndpoint).
+				TransformInputFunc(toCreateVpcEndpointInput).
+				TransformOutputFunc(fromCreateVpcEndpointOutput).
+				Build(),
+			get:  getFromList(listVpcEndpointsHandler, "VpcEndpointIds"),
+			list: listVpcEndpointsHandler,
+			delete: NewBuilder[ec2.DeleteVpcEndpointsInput, ec2.DeleteVpcEndpointsOutput](c).
+				Op(deleteVpcEndpoints).
+				TransformInputFunc(toDeleteVpcEndpointsInput).
+				TransformOutputFunc(fromDeleteVpcEndpointsOutput).
+				Build(),
+			schema:    vpcEndpointSchema,
+			dependsOn: []string{"aws_vpc"},
+			tagType:   string(types.ResourceTypeVpcEndpoint),
+			idPrefix:  "vpce-",
+		},
+		{
+			name: "aws_vpc_peering",
+			create: NewBuilder[vpcPeeringInput, vpcPeeringOutput](c).
+				Op(createVpcPeering).
+				TransformInputFunc(toVpcPeeringInput(c)).
+				TransformOutputFunc(fromVpcPeeringOutput).
+				Build(),
+			get:  getFromList(listVpcPeeringsHandler, "VpcPeeringConnectionIds"),
+			list: listVpcPeeringsHandler,
+			delete: NewBuilder[ec2.DeleteVpcPeeringConnectionInput, ec2.DeleteVpcPeeringConnectionOutput](c).
+				Op(deleteVpcPeering).
+				TransformInputFunc(toDeleteVpcPeeringInput).
+				TransformOutputFunc(fromDeleteVpcPeeringOutput).
+				Build(),
+			schema:    vpcPeeringSchema,
+			dependsOn: []string{"aws_vpc"},
+			tagType:   string(types.ResourceTypeVpcPeeringConnection),
+			idPrefix:  "pcx-",
+		},
+		{
+			name: "aws_network_acl",
+			create: NewBuilder[networkAclInput, networkAclOutput](c).
+				Op(createNetworkAcl).
+				TransformInputFunc(toNetworkAclInput).
+				TransformOutputFunc(fromNetworkAclOutput).
+				Build(),
+			get:  getFromList(listNetworkAclsHandler, "NetworkAclIds"),
+			list: listNetworkAclsHandler,
+			update: NewBuilder[networkAclInput, networkAclOutput](c).
+				Op(updateNetworkAcl).
+				TransformInputFunc(toNetworkAclInput).
+				TransformOutputFunc(fromNetworkAclOutput).
+				Build(),
+			delete: NewBuilder[ec2.DeleteNetworkAclInput, ec2.DeleteNetworkAclOutput](c).
+				Op(deleteNetworkAcl).
+				TransformInputFunc(toDeleteNetworkAclInput).
+				TransformOutputFunc(fromDeleteNetworkAclOutput).
+				Build(),
+			schema:       networkAclSchema,
+			updateSchema: networkAclUpdateSchema,
+			dependsOn:    []string{"aws_vpc", "aws_subnet"},
+			tagType:      string(types.ResourceTypeNetworkAcl),
+			idPrefix:     "acl-",
+		},
+		{
+			name: "aws_volume",
+			create: NewBuilder[volumeInput, volumeOutput](c).
+				Op(createVolume).
+				TransformInputFunc(toVolumeInput).
+				TransformOutputFunc(fromVolumeOutput).
+				Build(),
+			get:  getFromList(listVolumesHandler, "VolumeIds"),
+			list: listVolumesHandler,
+			update: NewBuilder[volumeInput, volumeOutput](c).
+				Op(updateVolume).
+				TransformInputFunc(toVolumeInput).
+				TransformOutputFunc(fromVolumeOutput).
+				Build(),
+			delete: NewBuilder[ec2.DeleteVolumeInput, ec2.DeleteVolumeOutput](c).
+				Op(deleteVolume).
+				TransformInputFunc(toDeleteVolumeInput).
+				TransformOutputFunc(fromDeleteVolumeOutput).
+				Build(),
+			schema:       volumeSchema,
+			updateSchema: volumeUpdateSchema,
+			tagType:      string(types.ResourceTypeVolume),
+			idPrefix:     "vol-",
+		},
+		{
+			name: "aws_snapshot",
+			create: NewBuilder[snapshotInput, snapshotOutput](c).
+				Op(createSnapshot).
+				TransformInputFunc(toSnapshotInput(c)).
+				TransformOutputFunc(fromSnapshotOutput).
+				Build(),
+			get:  getFromList(listSnapshotsHandler, "SnapshotIds"),
+			list: listSnapshotsHandler,
+			delete: NewBuilder[ec2.DeleteSnapshotInput, ec2.DeleteSnapshotOutput](c).
+				Op(deleteSnapshot).
+				TransformInputFunc(toDeleteSnapshotInput).
+				TransformOutputFunc(fromDeleteSnapshotOutput).
+				Build(),
+			schema:    snapshotSchema,
+			dependsOn: []string{"aws_volume"},
+			tagType:   string(types.ResourceTypeSnapshot),
+			idPrefix:  "snap-",
+		},
+		{
+			name:     "aws_image",
+			get:      getFromList(listImagesHandler, "ImageIds"),
+			list:     listImagesHandler,
+			tagType:  string(types.ResourceTypeImage),
+			idPrefix: "ami-",
+		},
+		{
+			name: "aws_availability_zones",
+			get:  getFromList(listAZsHandler, "ZoneIds"),
+			list: listAZsHandler,
+		},
+		{
+			name: "aws_igw",
+			create: NewBuilder[ec2.CreateInternetGatewayInput, ec2.CreateInternetGatewayOutput](c).
+				Op(createIgw).
+				TransformInputFunc(toCreateIgwInput).
+				TransformOutputFunc(fromCreateIgwOutput).
+				Build(),
+			get:  getFromList(listIgwsHandler, "InternetGatewayIds"),
+			list: listIgwsHandler,
+			delete: NewBuilder[ec2.DeleteInternetGatewayInput, ec2.DeleteInternetGatewayOutput](c).
+				Op(deleteIgw).
+				TransformInputFunc(toDeleteIgwInput).
+				TransformOutputFunc(fromDeleteIgwOutput).
+				Build(),
+			tagType:  string(types.ResourceTypeInternetGateway),
+			idPrefix: "igw-",
+		},
+		{
+			name: "aws_nat",
+			create: NewBuilder[ec2.CreateNatGatewayInput, ec2.CreateNatGatewayOutput](c).
+				Op(createNat).
+				TransformInputFunc(toCreateNatInput).
+				TransformOutputFunc(fromCreateNatOutput).
+				Build(),
+			get:  getFromList(listNatsHandler, "NatGatewayIds"),
+			list: listNatsHandler,
+			delete: NewBuilder[ec2.DeleteNatGatewayInput, ec2.DeleteNatGatewayOutput](c).
+				Op(deleteNat).
+				TransformInputFunc(toDeleteNatInput).
+				TransformOutputFunc(fromDeleteNatOutput).
+				Build(),
+			schema:    natGatewaySchema,
+			dependsOn: []string{"aws_subnet"},
+			tagType:   string(types.ResourceTypeNatgateway),
+			idPrefix:  "nat-",
+		},
+		{
+			name: "aws_user",
+			create: NewBuilder[iam.CreateUserInput, iam.CreateUserOutput](c).
+				Op(createUserFunc).
+				TransformInputFunc(toCreateUserInput).
+				TransformOutputFunc(fromCreateUserOutput).
+				Build(),
+			get: NewBuilder[iam.GetUserInput, iam.GetUserOutput](c).
+				Op(getUserFunc).
+				TransformInputFunc(toGetUserInput).
+				TransformOutputFunc(fromGetUserOutput).
+				Build(),
+			list: NewBuilder[iam.ListUsersInput, iam.ListUsersOutput](c).
+				Op(listUserFunc).
+				TransformInputFunc(toListUserInput).
+				TransformListOutputFunc(fromListUserOutput).
+				BuildList(),
+			delete: NewBuilder[iam.DeleteUserInput, iam.DeleteUserOutput](c).
+				Op(deleteUserFunc).
+				TransformInputFunc(toDeleteUserInput).
+				TransformOutputFunc(fromDeleteUserOutput).
+				Build(),
+			schema: userSchema,
+		},
+		{
+			name: "aws_access_key",
+			create: NewBuilder[iam.CreateAccessKeyInput, iam.CreateAccessKeyOutput](c).
+				Op(createAccessKeyFunc).
+				TransformInputFunc(toCreateAccessKeyInput).
+				TransformOutputFunc(fromCreateAccessKeyOutput).
+				Build(),
+			list: NewBuilder[iam.ListAccessKeysInput, iam.ListAccessKeysOutput](c).
+				Op(listAccessKeysFunc).
+				TransformInputFunc(toListAccessKeysInput).
+				TransformListOutputFunc(fromListAccessKeysOutput).
+				BuildList(),
+			delete: NewBuilder[iam.DeleteAccessKeyInput, iam.DeleteAccessKeyOutput](c).
+				Op(deleteAccessKeyFunc).
+				TransformInputFunc(toDeleteAccessKeyInput).
+				TransformOutputFunc(fromDeleteAccessKeyOutput).
+				Build(),
+			schema:    accessKeySchema,
+			dependsOn: []string{"aws_user"},
+		},
+	}
+}
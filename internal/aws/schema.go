@@ -0,0 +1,350 @@
+package aws
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+type fieldKind int
+
+const (
+	anyKind fieldKind = iota
+	stringKind
+	numberKind
+	boolKind
+	listKind
+	tableKind
+)
+
+func (k fieldKind) String() string {
+	switch k {
+	case stringKind:
+		return "string"
+	case numberKind:
+		return "number"
+	case boolKind:
+		return "boolean"
+	case listKind:
+		return "list"
+	case tableKind:
+		return "table"
+	}
+	return "any"
+}
+
+type format int
+
+const (
+	noFormat format = iota
+	cidrFormat
+	ipv6CidrFormat
+	arnFormat
+	// idFormat checks the prefix of an aws identifier (e.g. "vpc-").
+	idFormat
+)
+
+// field declares a field of an input. Fields are named by their snake_case name.
+type field struct {
+	kind     fieldKind
+	required bool
+	// enum lists the accepted values. They are compared regardless of the case like the decoder does.
+	enum []string
+	// format is checked on the value or, for lists, on every element.
+	format format
+	// prefix is the prefix of the identifier when format is idFormat.
+	prefix  string
+	aliases []string
+}
+
+// schema declares the fields of a resource input which are validated before calling aws.
+// Fields which are not declared are left to the decoder.
+type schema struct {
+	fields map[string]field
+	// oneOf lists fields of which at least one must be set.
+	oneOf []string
+}
+
+// validate returns an error naming the first field which does not match the schema.
+func (s *schema) validate(o lua.Object) error {
+	if s == nil {
+		return nil
+	}
+
+	names := make(map[string]string, len(s.fields))
+	for name, f := range s.fields {
+		names[name] = name
+		for _, alias := range f.aliases {
+			names[alias] = name
+		}
+	}
+
+	// keys are sorted to always report the same error first.
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	set := make(map[string]bool, len(o))
+	for _, key := range keys {
+		name, found := names[toSnakeCase(key)]
+		if !found || o[key] == nil {
+			continue
+		}
+		set[name] = true
+		if err := s.fields[name].validate(key, o[key]); err != nil {
+			return err
+		}
+	}
+
+	required := make([]string, 0, len(s.fields))
+	for name, f := range s.fields {
+		if f.required {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+	for _, name := range required {
+		if !set[name] {
+			return fmt.Errorf("field %q is required", name)
+		}
+	}
+
+	if len(s.oneOf) > 0 {
+		for _, name := range s.oneOf {
+			if set[name] {
+				return nil
+			}
+		}
+		return fmt.Errorf("one of the fields %s is required", quoteAll(s.oneOf))
+	}
+
+	return nil
+}
+
+func (f field) validate(path string, value interface{}) error {
+	switch f.kind {
+	case stringKind:
+		if _, ok := value.(string); !ok {
+			return fieldTypeError(path, f.kind, value)
+		}
+	case numberKind:
+		if _, ok := value.(float64); !ok {
+			return fieldTypeError(path, f.kind, value)
+		}
+	case boolKind:
+		if _, ok := value.(bool); !ok {
+			return fieldTypeError(path, f.kind, value)
+		}
+	case tableKind:
+		if _, ok := value.(lua.Object); !ok {
+			return fieldTypeError(path, f.kind, value)
+		}
+	case listKind:
+		switch list := value.(type) {
+		case []interface{}:
+			for i, item := range list {
+				if err := f.validateValue(fmt.Sprintf("%s[%d]", path, i+1), item); err != nil {
+					return err
+				}
+			}
+			return nil
+		case lua.Object:
+			if len(list) > 0 {
+				return fieldTypeError(path, f.kind, value)
+			}
+			return nil
+		}
+		// a scalar is a list of one element for the decoder.
+	}
+
+	return f.validateValue(path, value)
+}
+
+// validateValue checks the enum and the format of a scalar value.
+func (f field) validateValue(path string, value interface{}) error {
+	if len(f.enum) == 0 && f.format == noFormat {
+		return nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return fieldTypeError(path, stringKind, value)
+	}
+
+	if len(f.enum) > 0 {
+		valid := false
+		for _, e := range f.enum {
+			if strings.EqualFold(e, s) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("field %q: %q is not one of %s", path, s, strings.Join(f.enum, ", "))
+		}
+	}
+
+	switch f.format {
+	case cidrFormat:
+		if ip, _, err := net.ParseCIDR(s); err != nil || ip.To4() == nil {
+			return fmt.Errorf("field %q: %q is not a valid IPv4 CIDR", path, s)
+		}
+	case ipv6CidrFormat:
+		if ip, _, err := net.ParseCIDR(s); err != nil || ip.To4() != nil {
+			return fmt.Errorf("field %q: %q is not a valid IPv6 CIDR", path, s)
+		}
+	case arnFormat:
+		if parts := strings.SplitN(s, ":", 6); len(parts) != 6 || parts[0] != "arn" || parts[5] == "" {
+			return fmt.Errorf("field %q: %q is not a valid ARN", path, s)
+		}
+	case idFormat:
+		if !strings.HasPrefix(s, f.prefix) || len(s) == len(f.prefix) {
+			return fmt.Errorf("field %q: %q is not a valid id, expected %s...", path, s, f.prefix)
+		}
+	}
+
+	return nil
+}
+
+var (
+	fieldKinds = map[string]fieldKind{
+		"any":     anyKind,
+		"string":  stringKind,
+		"number":  numberKind,
+		"boolean": boolKind,
+		"list":    listKind,
+		"table":   tableKind,
+	}
+	formats = map[string]format{
+		"cidr":      cidrFormat,
+		"ipv6_cidr": ipv6CidrFormat,
+		"arn":       arnFormat,
+		"id":        idFormat,
+	}
+)
+
+// parseSchema parses a schema declared in lua. Each field is either the name of its type or a table:
+//
+//	{
+//	    name = "string",
+//	    vpc_id = { type = "string", required = true, format = "id", prefix = "vpc-" },
+//	    size = { type = "string", enum = { "small", "large" }, aliases = { "instance_size" } },
+//	}
+//
+// An empty object is no schema.
+func parseSchema(o lua.Object) (*schema, error) {
+	if len(o) == 0 {
+		return nil, nil
+	}
+
+	s := &schema{fields: make(map[string]field, len(o))}
+	for name, v := range o {
+		var f field
+		switch decl := v.(type) {
+		case string:
+			kind, found := fieldKinds[decl]
+			if !found {
+				return nil, fmt.Errorf("field %q: unknown type %q", name, decl)
+			}
+			f.kind = kind
+		case lua.Object:
+			var err error
+			if f, err = parseField(decl); err != nil {
+				return nil, fmt.Errorf("field %q: %w", name, err)
+			}
+		default:
+			return nil, fieldTypeError(name, tableKind, v)
+		}
+		s.fields[name] = f
+	}
+	return s, nil
+}
+
+func parseField(o lua.Object) (field, error) {
+	f := field{}
+	for key, v := range o {
+		switch key {
+		case "type":
+			kind, found := fieldKinds[fmt.Sprint(v)]
+			if !found {
+				return f, fmt.Errorf("unknown type %q", v)
+			}
+			f.kind = kind
+		case "required":
+			required, ok := v.(bool)
+			if !ok {
+				return f, fieldTypeError(key, boolKind, v)
+			}
+			f.required = required
+		case "format":
+			format, found := formats[fmt.Sprint(v)]
+			if !found {
+				return f, fmt.Errorf("unknown format %q", v)
+			}
+			f.format = format
+		case "prefix":
+			f.prefix = fmt.Sprint(v)
+		case "enum":
+			f.enum = toStrings(v)
+		case "aliases":
+			f.aliases = toStrings(v)
+		default:
+			return f, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	if f.format == idFormat && f.prefix == "" {
+		return f, fmt.Errorf("format id requires a prefix")
+	}
+	return f, nil
+}
+
+func toStrings(v interface{}) []string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return []string{fmt.Sprint(v)}
+	}
+	s := make([]string, 0, len(list))
+	for _, item := range list {
+		s = append(s, fmt.Sprint(item))
+	}
+	return s
+}
+
+func fieldTypeError(path string, expected fieldKind, value interface{}) error {
+	return fmt.Errorf("field %q: expected %s, got %s", path, expected, luaTypeName(value))
+}
+
+// enumOf converts the values of a sdk enum into strings.
+func enumOf[T ~string](values []T) []string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, string(v))
+	}
+	return s
+}
+
+func idField(prefix string) field {
+	return field{kind: stringKind, format: idFormat, prefix: prefix}
+}
+
+func idListField(prefix string) field {
+	return field{kind: listKind, format: idFormat, prefix: prefix}
+}
+
+func required(f field) field {
+	f.required = true
+	return f
+}
+
+func quoteAll(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, n := range names {
+		quoted = append(quoted, fmt.Sprintf("%q", n))
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,143 @@
+package aws
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		name   string
+		schema *schema
+		input  lua.Object
+		err    string
+	}{
+		{
+			name:   "valid subnet",
+			schema: subnetSchema,
+			input:  lua.Object{"vpc_id": "vpc-1", "cidr": "10.0.1.0/24", "tags": lua.Object{"k": "v"}},
+		},
+		{
+			name:   "sdk field names",
+			schema: subnetSchema,
+			input:  lua.Object{"VpcId": "vpc-1", "CidrBlock": "10.0.1.0/24"},
+		},
+		{
+			name:   "missing required field",
+			schema: subnetSchema,
+			input:  lua.Object{"cidr": "10.0.1.0/24"},
+			err:    `field "vpc_id" is required`,
+		},
+		{
+			name:   "invalid cidr",
+			schema: subnetSchema,
+			input:  lua.Object{"vpc_id": "vpc-1", "cidr": "10.0.1/24"},
+			err:    `field "cidr": "10.0.1/24" is not a valid IPv4 CIDR`,
+		},
+		{
+			name:   "ipv6 cidr",
+			schema: subnetSchema,
+			input:  lua.Object{"vpc_id": "vpc-1", "ipv6_cidr_block": "10.0.1.0/24"},
+			err:    `field "ipv6_cidr_block": "10.0.1.0/24" is not a valid IPv6 CIDR`,
+		},
+		{
+			name:   "wrong id prefix",
+			schema: subnetSchema,
+			input:  lua.Object{"vpc_id": "subnet-1", "cidr": "10.0.1.0/24"},
+			err:    `field "vpc_id": "subnet-1" is not a valid id, expected vpc-...`,
+		},
+		{
+			name:   "wrong type",
+			schema: subnetSchema,
+			input:  lua.Object{"vpc_id": 12.0, "cidr": "10.0.1.0/24"},
+			err:    `field "vpc_id": expected string, got number`,
+		},
+		{
+			name:   "one of",
+			schema: subnetSchema,
+			input:  lua.Object{"vpc_id": "vpc-1"},
+			err:    `one of the fields "cidr_block", "ipv6_cidr_block", "ipv4_ipam_pool_id" is required`,
+		},
+		{
+			name:   "enum regardless of the case",
+			schema: vpcEndpointSchema,
+			input:  lua.Object{"vpc_id": "vpc-1", "service_name": "com.amazonaws.eu-west-1.s3", "type": "gateway"},
+		},
+		{
+			name:   "invalid enum",
+			schema: vpcEndpointSchema,
+			input:  lua.Object{"vpc_id": "vpc-1", "service_name": "com.amazonaws.eu-west-1.s3", "type": "private"},
+			err:    `field "type": "private" is not one of Interface, Gateway, GatewayLoadBalancer`,
+		},
+		{
+			name:   "list element",
+			schema: vpcEndpointSchema,
+			input:  lua.Object{"vpc_id": "vpc-1", "service_name": "s3", "subnet_ids": []interface{}{"subnet-1", "sg-1"}},
+			err:    `field "subnet_ids[2]": "sg-1" is not a valid id, expected subnet-...`,
+		},
+		{
+			name:   "scalar for a list",
+			schema: vpcEndpointSchema,
+			input:  lua.Object{"vpc_id": "vpc-1", "service_name": "s3", "route_table_ids": "rtb-1"},
+		},
+		{
+			name:   "arn",
+			schema: userSchema,
+			input:  lua.Object{"username": "bob", "permissions_boundary": "policy"},
+			err:    `field "permissions_boundary": "policy" is not a valid ARN`,
+		},
+		{
+			name:   "no schema",
+			schema: nil,
+			input:  lua.Object{"anything": 1.0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.validate(tt.input)
+			if tt.err == "" {
+				Expect(err).To(BeNil())
+				return
+			}
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(Equal(tt.err))
+		})
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	RegisterTestingT(t)
+
+	s, err := parseSchema(lua.Object{
+		"name":   "string",
+		"vpc_id": lua.Object{"type": "string", "required": true, "format": "id", "prefix": "vpc-"},
+		"size":   lua.Object{"type": "string", "enum": []interface{}{"small", "large"}, "aliases": []interface{}{"instance_size"}},
+	})
+	Expect(err).To(BeNil())
+	Expect(s.fields["name"]).To(Equal(field{kind: stringKind}))
+	Expect(s.fields["vpc_id"]).To(Equal(required(idField("vpc-"))))
+
+	Expect(s.validate(lua.Object{"vpc_id": "vpc-1", "instance_size": "small"})).To(Succeed())
+	Expect(s.validate(lua.Object{"vpc_id": "vpc-1", "instance_size": "medium"})).ToNot(Succeed())
+	Expect(s.validate(lua.Object{"name": "bastion"})).ToNot(Succeed())
+
+	s, err = parseSchema(lua.Object{})
+	Expect(err).To(BeNil())
+	Expect(s).To(BeNil())
+
+	for _, invalid := range []lua.Object{
+		{"name": "text"},
+		{"name": lua.Object{"type": "string", "format": "email"}},
+		{"name": lua.Object{"type": "string", "format": "id"}},
+		{"name": lua.Object{"type": "string", "min": 1.0}},
+		{"name": 1.0},
+	} {
+		_, err := parseSchema(invalid)
+		Expect(err).ToNot(BeNil())
+	}
+}
@@ -0,0 +1,243 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+// Tag adds or overwrites the tags of a resource. The id is either an ec2 id or the ARN of an iam user or role.
+func (a *AwsProvider) Tag(ctx context.Context, id string, tags map[string]string) error {
+	if strings.HasPrefix(id, "arn:") {
+		return tagIamResource(ctx, a.config, id, tags)
+	}
+
+	ec2Client, err := createEc2Client(ctx, a.config)
+	if err != nil {
+		return err
+	}
+	_, err = ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{id},
+		Tags:      toEc2Tags(tags),
+	})
+	return err
+}
+
+// Untag removes the tags with the given keys from a resource.
+// An empty list of keys is an error: ec2 would remove all the tags.
+func (a *AwsProvider) Untag(ctx context.Context, id string, keys []string) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("at least one tag key is required")
+	}
+	if strings.HasPrefix(id, "arn:") {
+		return untagIamResource(ctx, a.config, id, keys)
+	}
+
+	ec2Client, err := createEc2Client(ctx, a.config)
+	if err != nil {
+		return err
+	}
+	tags := make([]types.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, types.Tag{Key: aws.String(k)})
+	}
+	_, err = ec2Client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: []string{id},
+		Tags:      tags,
+	})
+	return err
+}
+
+// FindByTag returns the ec2 resources having all the tags: {id, type, tags}.
+// resourceTypes restricts the search to some types, either resource types of the lua module (aws_vpc)
+// or ec2 resource types (vpc).
+func (a *AwsProvider) FindByTag(ctx context.Context, tags map[string]string, resourceTypes []string) ([]lua.Object, error) {
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("at least one tag is required")
+	}
+
+	ec2Client, err := createEc2Client(ctx, a.config)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	filters := []types.Filter{{Name: aws.String("key"), Values: keys}}
+	if len(resourceTypes) > 0 {
+		values := make([]string, 0, len(resourceTypes))
+		for _, t := range resourceTypes {
+			if rt, err := a.registry.lookup(t); err == nil && rt.tagType != "" {
+				t = rt.tagType
+			}
+			values = append(values, t)
+		}
+		filters = append(filters, types.Filter{Name: aws.String("resource-type"), Values: values})
+	}
+
+	matching, err := describeTags(ctx, ec2Client, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(matching))
+	for _, r := range matching {
+		if HasTags(r.tags, tags) {
+			ids = append(ids, r.id)
+		}
+	}
+	if len(ids) == 0 {
+		return []lua.Object{}, nil
+	}
+
+	// the resources are described again to get all their tags and not only the matching ones.
+	resources, err := describeTags(ctx, ec2Client, []types.Filter{{Name: aws.String("resource-id"), Values: ids}})
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]lua.Object, 0, len(resources))
+	for _, r := range resources {
+		found = append(found, lua.Object{
+			"id":   r.id,
+			"type": a.resourceTypeName(r.resourceType),
+			"tags": r.tags,
+		})
+	}
+	return found, nil
+}
+
+type taggedResource struct {
+	id           string
+	resourceType types.ResourceType
+	tags         lua.Object
+}
+
+// describeTags returns the resources of the tags matching the filters sorted by id.
+func describeTags(ctx context.Context, client *ec2.Client, filters []types.Filter) ([]taggedResource, error) {
+	byId := make(map[string]*taggedResource)
+	paginator := ec2.NewDescribeTagsPaginator(client, &ec2.DescribeTagsInput{Filters: filters})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range page.Tags {
+			id := aws.ToString(t.ResourceId)
+			r, found := byId[id]
+			if !found {
+				r = &taggedResource{id: id, resourceType: t.ResourceType, tags: lua.Object{}}
+				byId[id] = r
+			}
+			r.tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+		}
+	}
+
+	resources := make([]taggedResource, 0, len(byId))
+	for _, r := range byId {
+		resources = append(resources, *r)
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].id < resources[j].id })
+	return resources, nil
+}
+
+// HasTags returns true when the tags contain all the expected ones.
+func HasTags(actual lua.Object, expected map[string]string) bool {
+	for k, v := range expected {
+		if value, found := actual[k]; !found || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// resourceTypeName returns the name of the registered resource type of an ec2 tag resource type.
+func (a *AwsProvider) resourceTypeName(rt types.ResourceType) string {
+	if name, found := a.registry.byTagType(string(rt)); found {
+		return name
+	}
+	return string(rt)
+}
+
+func toEc2Tags(tags map[string]string) []types.Tag {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ec2Tags := make([]types.Tag, 0, len(tags))
+	for _, k := range keys {
+		ec2Tags = append(ec2Tags, types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return ec2Tags
+}
+
+func toIamTags(tags map[string]string) []iamtypes.Tag {
+	ec2Tags := toEc2Tags(tags)
+	iamTags := make([]iamtypes.Tag, 0, len(ec2Tags))
+	for _, t := range ec2Tags {
+		iamTags = append(iamTags, iamtypes.Tag{Key: t.Key, Value: t.Value})
+	}
+	return iamTags
+}
+
+// parseIamArn returns the kind ("user" or "role") and the name of the iam resource.
+// arn:aws:iam::123456789012:user/path/bob -> user, bob
+func parseIamArn(arn string) (string, string, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "iam" {
+		return "", "", fmt.Errorf("unsupported resource %q: only iam users and roles can be tagged by ARN", arn)
+	}
+	resource := strings.Split(parts[5], "/")
+	if len(resource) < 2 || (resource[0] != "user" && resource[0] != "role") {
+		return "", "", fmt.Errorf("unsupported resource %q: only iam users and roles can be tagged by ARN", arn)
+	}
+	return resource[0], resource[len(resource)-1], nil
+}
+
+func tagIamResource(ctx context.Context, config ClientConfiguration, arn string, tags map[string]string) error {
+	kind, name, err := parseIamArn(arn)
+	if err != nil {
+		return err
+	}
+	iamClient, err := createIamClient(ctx, config)
+	if err != nil {
+		return err
+	}
+	if kind == "role" {
+		_, err = iamClient.TagRole(ctx, &iam.TagRoleInput{RoleName: aws.String(name), Tags: toIamTags(tags)})
+		return err
+	}
+	_, err = iamClient.TagUser(ctx, &iam.TagUserInput{UserName: aws.String(name), Tags: toIamTags(tags)})
+	return err
+}
+
+func untagIamResource(ctx context.Context, config ClientConfiguration, arn string, keys []string) error {
+	kind, name, err := parseIamArn(arn)
+	if err != nil {
+		return err
+	}
+	iamClient, err := createIamClient(ctx, config)
+	if err != nil {
+		return err
+	}
+	if kind == "role" {
+		_, err = iamClient.UntagRole(ctx, &iam.UntagRoleInput{RoleName: aws.String(name), TagKeys: keys})
+		return err
+	}
+	_, err = iamClient.UntagUser(ctx, &iam.UntagUserInput{UserName: aws.String(name), TagKeys: keys})
+	return err
+}
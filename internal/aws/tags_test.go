@@ -0,0 +1,118 @@
+package aws
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+func TestParseIamArn(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		arn  string
+		kind string
+		name string
+		err  bool
+	}{
+		{arn: "arn:aws:iam::123456789012:user/bob", kind: "user", name: "bob"},
+		{arn: "arn:aws:iam::123456789012:user/division/team/bob", kind: "user", name: "bob"},
+		{arn: "arn:aws:iam::123456789012:role/admin", kind: "role", name: "admin"},
+		{arn: "arn:aws:iam::123456789012:policy/admin", err: true},
+		{arn: "arn:aws:s3:::bucket", err: true},
+		{arn: "arn:aws:iam", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arn, func(t *testing.T) {
+			kind, name, err := parseIamArn(tt.arn)
+			if tt.err {
+				Expect(err).ToNot(BeNil())
+				return
+			}
+			Expect(err).To(BeNil())
+			Expect(kind).To(Equal(tt.kind))
+			Expect(name).To(Equal(tt.name))
+		})
+	}
+}
+
+func TestResourceType(t *testing.T) {
+	RegisterTestingT(t)
+
+	p := New(ClientConfiguration{})
+	for id, resource := range map[string]string{
+		"vpc-0a1b2c3d":                       "aws_vpc",
+		"vpce-0a1b2c3d":                      "aws_vpc_endpoint",
+		"subnet-0a1b2c3d":                    "aws_subnet",
+		"igw-0a1b2c3d":                       "aws_igw",
+		"arn:aws:iam::123456789012:user/bob": "aws_user",
+		"arn:aws:iam::123456789012:role/ci":  "aws_role",
+	} {
+		name, err := p.ResourceType(id)
+		Expect(err).To(BeNil())
+		Expect(name).To(Equal(resource), id)
+	}
+
+	_, err := p.ResourceType("sg-0a1b2c3d")
+	Expect(err).To(MatchError(`unknown resource type of "sg-0a1b2c3d"`))
+	_, err = p.ResourceType("arn:aws:s3:::bucket")
+	Expect(err).ToNot(BeNil())
+}
+
+func TestHasTags(t *testing.T) {
+	RegisterTestingT(t)
+
+	actual := lua.Object{"env": "prod", "team": "infra"}
+	Expect(HasTags(actual, map[string]string{"env": "prod"})).To(BeTrue())
+	Expect(HasTags(actual, map[string]string{"env": "prod", "team": "infra"})).To(BeTrue())
+	Expect(HasTags(actual, map[string]string{"env": "dev"})).To(BeFalse())
+	Expect(HasTags(actual, map[string]string{"owner": "bob"})).To(BeFalse())
+}
+
+func TestToEc2Tags(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(toEc2Tags(map[string]string{"b": "2", "a": "1"})).To(Equal([]types.Tag{
+		{Key: aws.String("a"), Value: aws.String("1")},
+		{Key: aws.String("b"), Value: aws.String("2")},
+	}))
+
+	a := New(ClientConfiguration{})
+	Expect(a.resourceTypeName(types.ResourceTypeVpc)).To(Equal("aws_vpc"))
+	Expect(a.resourceTypeName(types.ResourceTypeInstance)).To(Equal("instance"))
+}
+
+func TestUntag(t *testing.T) {
+	RegisterTestingT(t)
+
+	bodies := []string{}
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/xml"}},
+			Body:       io.NopCloser(strings.NewReader(`<DeleteTagsResponse><return>true</return></DeleteTagsResponse>`)),
+			Request:    req,
+		}, nil
+	})}
+	p := New(ClientConfiguration{AccessKey: "test", SecretKey: "test", Region: "us-east-1", HTTPClient: client})
+
+	Expect(p.Untag(context.TODO(), "vpc-1", []string{"team"})).To(Succeed())
+	Expect(bodies).To(HaveLen(1))
+	Expect(bodies[0]).To(ContainSubstring("Action=DeleteTags"))
+	Expect(bodies[0]).To(ContainSubstring("Tag.1.Key=team"))
+
+	// an empty list would remove all the tags of the resource.
+	Expect(p.Untag(context.TODO(), "vpc-1", []string{})).To(MatchError("at least one tag key is required"))
+	Expect(p.Untag(context.TODO(), "arn:aws:iam::123456789012:user/bob", nil)).ToNot(Succeed())
+	Expect(bodies).To(HaveLen(1))
+}
@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/tupyy/aws-lua/internal/logger"
+)
+
+// addTracing adds to the stack of an operation the middleware logging its calls at the debug level.
+func addTracing(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Trace", trace), middleware.After)
+}
+
+// trace logs the service, operation, duration, request id and retries of the call, and its redacted input and output.
+func trace(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	log := logger.Default()
+	if !log.Enabled(logger.LevelDebug) {
+		return next.HandleInitialize(ctx, in)
+	}
+
+	start := time.Now()
+	out, metadata, err := next.HandleInitialize(ctx, in)
+
+	requestID, _ := awsmiddleware.GetRequestIDMetadata(metadata)
+	var respErr *awshttp.ResponseError
+	if requestID == "" && errors.As(err, &respErr) {
+		requestID = respErr.ServiceRequestID()
+	}
+	retries := 0
+	if attempts, ok := retry.GetAttemptResults(metadata); ok && len(attempts.Results) > 0 {
+		retries = len(attempts.Results) - 1
+	}
+
+	fields := []interface{}{
+		"service", awsmiddleware.GetServiceID(ctx),
+		"operation", awsmiddleware.GetOperationName(ctx),
+		"duration", time.Since(start).Round(time.Millisecond),
+		"request_id", requestID,
+		"retries", retries,
+		"input", in.Parameters,
+	}
+	if err != nil {
+		log.Debug("aws call failed", append(fields, "error", err)...)
+		return out, metadata, err
+	}
+	output := logger.Redact(out.Result)
+	if m, ok := output.(map[string]interface{}); ok {
+		delete(m, "ResultMetadata")
+	}
+	log.Debug("aws call", append(fields, "output", output)...)
+	return out, metadata, err
+}
@@ -1,57 +1,116 @@
 package aws
 
 import (
+	"fmt"
 	"reflect"
+	"time"
 
+	"github.com/aws/smithy-go/middleware"
 	"github.com/tupyy/aws-lua/internal/lua"
 )
 
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	metadataType = reflect.TypeOf(middleware.Metadata{})
+)
+
+// toLua converts a sdk struct into a lua object.
+// Nil pointers, nil slices and nil maps are skipped. Times are converted to RFC3339 strings,
+// enums to strings, numbers to int64 or float64, slices to lists and maps to objects.
 func toLua(t any) lua.Object {
-	return walk(reflect.ValueOf(t))
+	o, ok := walk(reflect.ValueOf(t)).(lua.Object)
+	if !ok {
+		return lua.Object{}
+	}
+	return o
 }
 
-func walk(v reflect.Value) lua.Object {
-	o := lua.Object{}
+// walk returns the lua representation of v or nil if v holds no value.
+func walk(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
 
-	if v.Type().Kind() != reflect.Struct {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return walk(v.Elem())
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).Format(time.RFC3339)
+		}
+		return walkStruct(v)
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		// blobs are returned as strings.
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes())
+		}
+		return walkList(v)
+	case reflect.Array:
+		return walkList(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		o := lua.Object{}
+		iter := v.MapRange()
+		for iter.Next() {
+			if value := walk(iter.Value()); value != nil {
+				o[fmt.Sprint(iter.Key().Interface())] = value
+			}
+		}
 		return o
 	}
 
+	return nil
+}
+
+func walkStruct(v reflect.Value) lua.Object {
+	o := lua.Object{}
+	t := v.Type()
+
 	for i := 0; i < v.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Type == metadataType {
+			continue
+		}
+
 		f := v.Field(i)
-		if f.IsZero() {
+		// unset enums are empty strings.
+		if f.Kind() == reflect.String && f.Len() == 0 {
 			continue
 		}
 
-		if f.Type().Kind() == reflect.Pointer {
-			f = f.Elem()
-		}
-
-		name := reflect.Indirect(v).Type().Field(i).Name
-
-		switch f.Type().Kind() {
-		case reflect.Struct:
-			o[name] = walk(f)
-		case reflect.String:
-			o[name] = f.String()
-		case reflect.Bool:
-			o[name] = f.Bool()
-		case reflect.Int:
-			o[name] = f.Int()
-		case reflect.Array, reflect.Slice:
-			if f.Len() != 0 {
-				arr := make([]interface{}, 0, f.Len())
-				for i := 0; i < f.Len(); i++ {
-					if f.Index(i).Type().Kind() == reflect.Struct {
-						arr = append(arr, walk(f.Index(i)))
-					} else {
-						arr = append(arr, f.Index(i).Interface())
-					}
-				}
-				o[name] = arr
-			}
+		if value := walk(f); value != nil {
+			o[field.Name] = value
 		}
 	}
 
 	return o
 }
+
+func walkList(v reflect.Value) []interface{} {
+	arr := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		// nil elements are skipped to not leave holes in lua arrays.
+		if value := walk(v.Index(i)); value != nil {
+			arr = append(arr, value)
+		}
+	}
+	return arr
+}
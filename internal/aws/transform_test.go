@@ -3,11 +3,14 @@ package aws
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/lua"
 )
 
 type b struct {
@@ -90,3 +93,116 @@ func TestFrom3(t *testing.T) {
 	o := toLua(a)
 	fmt.Printf("%+v\n", o)
 }
+
+func TestToLua(t *testing.T) {
+	RegisterTestingT(t)
+
+	createTime := time.Date(2023, 7, 14, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		input    any
+		expected lua.Object
+	}{
+		{
+			name: "numbers, booleans and enums",
+			input: ec2.DescribeSubnetsOutput{
+				Subnets: []types.Subnet{
+					{
+						SubnetId:                aws.String("subnet-1"),
+						AvailableIpAddressCount: aws.Int32(251),
+						MapPublicIpOnLaunch:     aws.Bool(false),
+						State:                   types.SubnetStateAvailable,
+					},
+				},
+			},
+			expected: lua.Object{
+				"Subnets": []interface{}{
+					lua.Object{
+						"SubnetId":                "subnet-1",
+						"AvailableIpAddressCount": int64(251),
+						"MapPublicIpOnLaunch":     false,
+						"State":                   "available",
+					},
+				},
+			},
+		},
+		{
+			name: "times and empty lists",
+			input: ec2.DescribeVolumesOutput{
+				Volumes: []types.Volume{
+					{
+						VolumeId:    aws.String("vol-1"),
+						CreateTime:  &createTime,
+						Size:        aws.Int32(20),
+						Attachments: []types.VolumeAttachment{},
+					},
+				},
+			},
+			expected: lua.Object{
+				"Volumes": []interface{}{
+					lua.Object{
+						"VolumeId":    "vol-1",
+						"CreateTime":  "2023-07-14T10:30:00Z",
+						"Size":        int64(20),
+						"Attachments": []interface{}{},
+					},
+				},
+			},
+		},
+		{
+			name: "floats",
+			input: types.EbsOptimizedInfo{
+				BaselineIops:             aws.Int32(3000),
+				BaselineThroughputInMBps: aws.Float64(62.5),
+			},
+			expected: lua.Object{
+				"BaselineIops":             int64(3000),
+				"BaselineThroughputInMBps": 62.5,
+			},
+		},
+		{
+			name: "maps",
+			input: iam.GetAccountSummaryOutput{
+				SummaryMap: map[string]int32{"Users": 3, "Groups": 1},
+			},
+			expected: lua.Object{
+				"SummaryMap": lua.Object{"Users": int64(3), "Groups": int64(1)},
+			},
+		},
+		{
+			name: "string slices",
+			input: ec2.CreateVpcEndpointOutput{
+				VpcEndpoint: &types.VpcEndpoint{
+					VpcEndpointId:     aws.String("vpce-1"),
+					RouteTableIds:     []string{"rtb-1", "rtb-2"},
+					CreationTimestamp: &createTime,
+				},
+			},
+			expected: lua.Object{
+				"VpcEndpoint": lua.Object{
+					"VpcEndpointId":     "vpce-1",
+					"RouteTableIds":     []interface{}{"rtb-1", "rtb-2"},
+					"CreationTimestamp": "2023-07-14T10:30:00Z",
+				},
+			},
+		},
+		{
+			name: "slices of pointers to structs",
+			input: struct {
+				Items []*b
+			}{
+				Items: []*b{{"one"}, nil, {"two"}},
+			},
+			expected: lua.Object{
+				"Items": []interface{}{lua.Object{"BFoo": "one"}, lua.Object{"BFoo": "two"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Expect(toLua(tt.input)).To(Equal(tt.expected))
+		})
+	}
+}
@@ -0,0 +1,280 @@
+// Package cassette records the http requests sent to aws into cassette files and plays them back without the network.
+//
+// Each request/response pair is written into its own json file, named after its position and its operation
+// (e.g. 0003-ec2-DescribeVpcs.json). Signatures, credentials and secrets are scrubbed before writing.
+// On replay, requests must come in the recorded order and call the same operations.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const redacted = "REDACTED"
+
+// Interaction is a recorded request/response pair.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Operation string      `json:"operation"`
+	Header    http.Header `json:"header"`
+	Body      string      `json:"body"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+var (
+	// scrubbedHeaders carry the signature and the credentials of the requests.
+	scrubbedHeaders = []string{"Authorization", "X-Amz-Security-Token"}
+	// secretParams matches the query parameters holding secrets like passwords.
+	secretParams = regexp.MustCompile(`(?i)(password|secret)`)
+	// secretElements matches the xml elements holding secrets in the responses.
+	secretElements = regexp.MustCompile(`(?s)<(SecretAccessKey|Password|SessionToken)>.*?</(SecretAccessKey|Password|SessionToken)>`)
+	// unsafeChars are replaced in the operation part of the file names.
+	unsafeChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+	// cassetteName matches the file names written by fileName.
+	cassetteName = regexp.MustCompile(`^[0-9]{4}-.+\.json$`)
+)
+
+// Recorder is a http.RoundTripper sending the requests with the transport and writing them into the directory.
+type Recorder struct {
+	dir       string
+	transport http.RoundTripper
+	mu        sync.Mutex
+	count     int
+}
+
+// NewRecorder returns a recorder writing into the directory, which is created if needed.
+// Existing cassettes are removed so the directory holds only one run, the other files are kept.
+// The requests are sent with the transport, or with http.DefaultTransport when nil.
+func NewRecorder(dir string, transport http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := cassettes(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil {
+			return nil, err
+		}
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{dir: dir, transport: transport}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	i := Interaction{
+		Request: Request{
+			Method:    req.Method,
+			URL:       req.URL.String(),
+			Operation: operation(req.URL, reqBody),
+			Header:    scrubHeader(req.Header),
+			Body:      scrubForm(reqBody),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       secretElements.ReplaceAllString(respBody, "<$1>"+redacted+"</$2>"),
+		},
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.count++
+	if err := write(filepath.Join(r.dir, fileName(r.count, req.URL, i.Request.Operation)), i); err != nil {
+		return nil, fmt.Errorf("cannot record %s: %w", i.Request.Operation, err)
+	}
+	return resp, nil
+}
+
+// Player is a http.RoundTripper answering the requests with the interactions of a directory.
+type Player struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	names        []string
+	next         int
+}
+
+// NewPlayer loads the cassettes of the directory in their recorded order.
+func NewPlayer(dir string) (*Player, error) {
+	files, err := cassettes(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no cassette found in %s", dir)
+	}
+
+	p := &Player{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var i Interaction
+		if err := json.Unmarshal(data, &i); err != nil {
+			return nil, fmt.Errorf("invalid cassette %s: %w", f, err)
+		}
+		p.interactions = append(p.interactions, i)
+		p.names = append(p.names, filepath.Base(f))
+	}
+	return p, nil
+}
+
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	op := operation(req.URL, body)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.next >= len(p.interactions) {
+		return nil, fmt.Errorf("no cassette left for %s %s", req.URL.Host, op)
+	}
+	i := p.interactions[p.next]
+	recorded, err := url.Parse(i.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", p.names[p.next], err)
+	}
+	if i.Request.Method != req.Method || recorded.Host != req.URL.Host || i.Request.Operation != op {
+		return nil, fmt.Errorf("cassette %s recorded %s %s but got %s %s", p.names[p.next], recorded.Host, i.Request.Operation, req.URL.Host, op)
+	}
+	p.next++
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+		StatusCode:    i.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Response.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(i.Response.Body)),
+		ContentLength: int64(len(i.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Remaining returns the number of interactions which have not been played back.
+func (p *Player) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.interactions) - p.next
+}
+
+// operation returns the name of the operation of a query request (ec2, iam): the Action parameter of the body.
+func operation(u *url.URL, body string) string {
+	if values, err := url.ParseQuery(body); err == nil && values.Get("Action") != "" {
+		return values.Get("Action")
+	}
+	if action := u.Query().Get("Action"); action != "" {
+		return action
+	}
+	return u.Path
+}
+
+// cassettes returns the paths of the cassettes of the directory in their recorded order.
+func cassettes(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	matched := []string{}
+	for _, f := range files {
+		if cassetteName.MatchString(filepath.Base(f)) {
+			matched = append(matched, f)
+		}
+	}
+	sort.Strings(matched)
+	return matched, nil
+}
+
+func fileName(n int, u *url.URL, op string) string {
+	service := strings.SplitN(u.Host, ".", 2)[0]
+	clean := strings.Trim(unsafeChars.ReplaceAllString(op, "-"), "-")
+	return fmt.Sprintf("%04d-%s-%s.json", n, service, clean)
+}
+
+// readBody reads the body and replaces it with a copy so it can be read again.
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+	data, err := io.ReadAll(*body)
+	if err != nil {
+		return "", err
+	}
+	(*body).Close()
+	*body = io.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
+
+func scrubHeader(h http.Header) http.Header {
+	scrubbed := h.Clone()
+	for _, name := range scrubbedHeaders {
+		if scrubbed.Get(name) != "" {
+			scrubbed.Set(name, redacted)
+		}
+	}
+	return scrubbed
+}
+
+// scrubForm redacts the secret parameters of a form encoded body. Other bodies are kept as they are.
+func scrubForm(body string) string {
+	values, err := url.ParseQuery(body)
+	if err != nil || values.Get("Action") == "" {
+		return body
+	}
+	for key := range values {
+		if secretParams.MatchString(key) {
+			values.Set(key, redacted)
+		}
+	}
+	return values.Encode()
+}
+
+func write(path string, i Interaction) error {
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
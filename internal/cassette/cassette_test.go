@@ -0,0 +1,80 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRecordReplay(t *testing.T) {
+	RegisterTestingT(t)
+	dir := t.TempDir()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, "<CreateAccessKeyResult><AccessKeyId>AKIA1</AccessKeyId><SecretAccessKey>s3cr3t</SecretAccessKey></CreateAccessKeyResult>")
+	}))
+	defer server.Close()
+
+	send := func(client *http.Client, action string) (string, error) {
+		form := url.Values{"Action": {action}, "NewPassword": {"p4ss"}}
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(form.Encode()))
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIAEXAMPLE/20240101/us-east-1/iam/aws4_request")
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	recorder, err := NewRecorder(dir, nil)
+	Expect(err).To(BeNil())
+	body, err := send(&http.Client{Transport: recorder}, "CreateAccessKey")
+	Expect(err).To(BeNil())
+	Expect(body).To(ContainSubstring("s3cr3t"))
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	Expect(files).To(HaveLen(1))
+	Expect(filepath.Base(files[0])).To(Equal("0001-127-CreateAccessKey.json"))
+	data, _ := os.ReadFile(files[0])
+	Expect(string(data)).ToNot(ContainSubstring("s3cr3t"))
+	Expect(string(data)).ToNot(ContainSubstring("p4ss"))
+	Expect(string(data)).ToNot(ContainSubstring("AKIAEXAMPLE"))
+
+	player, err := NewPlayer(dir)
+	Expect(err).To(BeNil())
+	Expect(player.Remaining()).To(Equal(1))
+	client := &http.Client{Transport: player}
+
+	_, err = send(client, "DeleteAccessKey")
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("recorded 127.0.0.1"))
+
+	server.Close()
+	body, err = send(client, "CreateAccessKey")
+	Expect(err).To(BeNil())
+	Expect(body).To(ContainSubstring("<SecretAccessKey>REDACTED</SecretAccessKey>"))
+	Expect(player.Remaining()).To(Equal(0))
+
+	_, err = send(client, "CreateAccessKey")
+	Expect(err).ToNot(BeNil())
+
+	_, err = NewPlayer(t.TempDir())
+	Expect(err).ToNot(BeNil())
+
+	// recording again removes the cassettes only.
+	Expect(os.WriteFile(filepath.Join(dir, "config.json"), []byte("{}"), 0644)).To(Succeed())
+	_, err = NewRecorder(dir, nil)
+	Expect(err).To(BeNil())
+	files, _ = filepath.Glob(filepath.Join(dir, "*.json"))
+	Expect(files).To(Equal([]string{filepath.Join(dir, "config.json")}))
+}
@@ -0,0 +1,101 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+// Load replaces the resources of the provider with the ones of a fixture file.
+// The fixture is a json object of resource types, each a list of resources with their attributes and tags:
+//
+//	{
+//	    "aws_vpc": [{ "vpc_id": "vpc-0a1b2c3d4e5f67890", "cidr_block": "10.0.0.0/16", "tags": { "env": "dev" } }]
+//	}
+//
+// Types which are absent from the fixture are left untouched, so availability zones are only replaced when listed.
+func (p *Provider) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var fixture map[string][]interface{}
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+
+	for name, items := range fixture {
+		k, err := lookup(name)
+		if err != nil {
+			return fmt.Errorf("invalid fixture %s: %w", path, err)
+		}
+		records := make([]*record, 0, len(items))
+		for i, item := range items {
+			o, ok := toObject(item).(lua.Object)
+			if !ok {
+				return fmt.Errorf("invalid fixture %s: %s[%d] is not an object", path, name, i+1)
+			}
+			attrs, err := k.input(o)
+			if err != nil {
+				return fmt.Errorf("invalid fixture %s: %s[%d]: %w", path, name, i+1, err)
+			}
+			tags, err := inputTags(attrs)
+			if err != nil {
+				return fmt.Errorf("invalid fixture %s: %s[%d]: %w", path, name, i+1, err)
+			}
+			delete(attrs, "tags")
+			id := attrs.GetString(k.idAttr)
+			if id == "" {
+				return fmt.Errorf("invalid fixture %s: %s[%d] has no %s", path, name, i+1, k.idAttr)
+			}
+			records = append(records, &record{id: id, attrs: attrs, tags: tags})
+		}
+		p.records[name] = records
+	}
+	return nil
+}
+
+// Save writes the resources of the provider into a fixture file which can be loaded later.
+func (p *Provider) Save(path string) error {
+	fixture := make(map[string][]lua.Object, len(kinds))
+	for _, k := range kinds {
+		items := make([]lua.Object, 0, len(p.records[k.name]))
+		for _, r := range p.records[k.name] {
+			o := make(lua.Object, len(r.attrs)+1)
+			for key, v := range r.attrs {
+				o[key] = v
+			}
+			o["tags"] = tagMap(r.tags)
+			items = append(items, o)
+		}
+		fixture[k.name] = items
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// toObject converts the maps decoded from json into objects like the values coming from lua.
+func toObject(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		o := make(lua.Object, len(value))
+		for k, item := range value {
+			o[k] = toObject(item)
+		}
+		return o
+	case []interface{}:
+		list := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			list = append(list, toObject(item))
+		}
+		return list
+	}
+	return v
+}
@@ -0,0 +1,332 @@
+// Package fake implements an in-memory aws provider to develop scripts without an aws account.
+//
+// It simulates VPCs, subnets, internet gateways, NAT gateways, availability zones, users and access keys.
+// The resource types, their schemas and the defined types are those of the aws provider, the types which are not
+// simulated support no verb. Outputs have the same normalized shape and the errors of aws (invalid CIDR,
+// dependency violations, ...) are reproduced. Internet gateways are never attached to a VPC since the aws
+// provider has no verb to attach them.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tupyy/aws-lua/internal/aws"
+	"github.com/tupyy/aws-lua/internal/logger"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+const accountId = "123456789012"
+
+type record struct {
+	id    string
+	attrs lua.Object
+	tags  map[string]string
+}
+
+type Provider struct {
+	// types dispatches the verbs to the simulated kinds and to the defined types like the aws provider does.
+	types   *aws.AwsProvider
+	region  string
+	rand    *rand.Rand
+	records map[string][]*record
+}
+
+// New returns an empty provider with the availability zones of the region.
+func New(region string) *Provider {
+	if region == "" {
+		region = "us-east-1"
+	}
+	p := &Provider{
+		region:  region,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		records: make(map[string][]*record),
+	}
+	handlers := make(map[string]aws.Handlers, len(kinds))
+	for _, k := range kinds {
+		handlers[k.name] = p.handlers(k)
+	}
+	p.types = aws.NewWithHandlers(handlers)
+	p.seedAvailabilityZones()
+	return p
+}
+
+// Create validates the input against the schema of the aws provider before simulating the resource.
+func (p *Provider) Create(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	return p.types.Create(ctx, resource, o)
+}
+
+// Get returns the resource {id = ...}.
+func (p *Provider) Get(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	return p.types.Get(ctx, resource, o)
+}
+
+func (p *Provider) Update(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	return p.types.Update(ctx, resource, o)
+}
+
+func (p *Provider) Delete(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	return p.types.Delete(ctx, resource, o)
+}
+
+// List returns the resources matching the ids, the filters ({Name, Values} list or {name = values} map)
+// and the other attributes of the input.
+func (p *Provider) List(ctx context.Context, resource string, o lua.Object) ([]lua.Object, error) {
+	return p.types.List(ctx, resource, o)
+}
+
+// Types returns the resource types of the aws provider and the defined ones: {name, verbs, depends_on}.
+// The built-in types which are not simulated have no verbs.
+func (p *Provider) Types() []lua.Object {
+	return p.types.Types()
+}
+
+// Define registers a resource type implemented outside of the provider, like the aws provider does.
+func (p *Provider) Define(def lua.ResourceDefinition) error {
+	return p.types.Define(def)
+}
+
+// ResourceType returns the resource type of an ec2 id or of the ARN of an iam user, like the aws provider does.
+func (p *Provider) ResourceType(id string) (string, error) {
+	return p.types.ResourceType(id)
+}
+
+// handlers returns the verbs of the kind.
+func (p *Provider) handlers(k *kind) aws.Handlers {
+	h := aws.Handlers{
+		Get: func(ctx context.Context, o lua.Object) (lua.Object, error) {
+			return p.get(k, o)
+		},
+		List: func(ctx context.Context, o lua.Object) ([]lua.Object, error) {
+			return p.list(k, o)
+		},
+		Delete: func(ctx context.Context, o lua.Object) (lua.Object, error) {
+			return p.delete(k, o)
+		},
+	}
+	if k.create != nil {
+		h.Create = func(ctx context.Context, o lua.Object) (lua.Object, error) {
+			return p.create(k, o)
+		}
+	}
+	return h
+}
+
+func (p *Provider) create(k *kind, o lua.Object) (lua.Object, error) {
+	in, err := k.input(o)
+	if err != nil {
+		return lua.Object{}, err
+	}
+	if err := k.checkFields(in); err != nil {
+		return lua.Object{}, err
+	}
+	tags, err := inputTags(in)
+	if err != nil {
+		return lua.Object{}, err
+	}
+	attrs, err := k.create(p, in)
+	if err != nil {
+		return lua.Object{}, err
+	}
+
+	r := &record{id: fmt.Sprint(attrs[k.idAttr]), attrs: attrs, tags: tags}
+	p.records[k.name] = append(p.records[k.name], r)
+	logger.Default().Debug("fake resource created", "type", k.name, "id", r.id)
+	return k.render(r), nil
+}
+
+func (p *Provider) get(k *kind, o lua.Object) (lua.Object, error) {
+	id := o.GetString("id")
+	if id == "" {
+		return lua.Object{}, fmt.Errorf(`field "id" is required`)
+	}
+	r := p.find(k.name, id)
+	if r == nil {
+		return lua.Object{}, fmt.Errorf("resource %q not found", id)
+	}
+	return k.render(r), nil
+}
+
+func (p *Provider) delete(k *kind, o lua.Object) (lua.Object, error) {
+	in, err := k.input(o)
+	if err != nil {
+		return lua.Object{}, err
+	}
+	id := in.GetString(k.idAttr)
+	if id == "" {
+		return lua.Object{}, fmt.Errorf("invalid input: field %q is required", k.idAttr)
+	}
+	r := p.find(k.name, id)
+	if r == nil {
+		return lua.Object{}, apiError(k.notFoundCode, k.notFoundMessage, id)
+	}
+	if k.conflict != nil {
+		if err := k.conflict(p, r); err != nil {
+			return lua.Object{}, err
+		}
+	}
+
+	p.remove(k.name, id)
+	logger.Default().Debug("fake resource deleted", "type", k.name, "id", id)
+	return lua.Object{}, nil
+}
+
+func (p *Provider) list(k *kind, o lua.Object) ([]lua.Object, error) {
+	in, err := k.input(o)
+	if err != nil {
+		return nil, err
+	}
+	match, err := newMatcher(in)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []lua.Object{}
+	for _, r := range p.records[k.name] {
+		o := k.render(r)
+		if match(o) {
+			if k.hideOnList != "" {
+				delete(o, k.hideOnList)
+				delete(o.GetObject("raw"), pascalCase(k.hideOnList))
+			}
+			items = append(items, o)
+		}
+	}
+	return items, nil
+}
+
+func (p *Provider) Call(ctx context.Context, service, operation string, o lua.Object) (lua.Object, error) {
+	return lua.Object{}, fmt.Errorf("%s %s: operations cannot be called on the fake provider", service, operation)
+}
+
+// Tag adds or overwrites the tags of a resource. The id is either an ec2 id or the ARN of an iam user.
+func (p *Provider) Tag(ctx context.Context, id string, tags map[string]string) error {
+	r, err := p.findTaggable(id)
+	if err != nil {
+		return err
+	}
+	for k, v := range tags {
+		r.tags[k] = v
+	}
+	return nil
+}
+
+// Untag removes the tags with the given keys from a resource.
+// An empty list of keys is an error like with the aws provider.
+func (p *Provider) Untag(ctx context.Context, id string, keys []string) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("at least one tag key is required")
+	}
+	r, err := p.findTaggable(id)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		delete(r.tags, k)
+	}
+	return nil
+}
+
+// FindByTag returns the ec2 resources having all the tags: {id, type, tags}.
+func (p *Provider) FindByTag(ctx context.Context, tags map[string]string, resourceTypes []string) ([]lua.Object, error) {
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("at least one tag is required")
+	}
+
+	found := []lua.Object{}
+	for _, k := range kinds {
+		tagType := p.types.TagType(k.name)
+		if tagType == "" || !selected(k.name, tagType, resourceTypes) {
+			continue
+		}
+		for _, r := range p.records[k.name] {
+			if !aws.HasTags(tagMap(r.tags), tags) {
+				continue
+			}
+			found = append(found, lua.Object{"id": r.id, "type": k.name, "tags": tagMap(r.tags)})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].GetString("id") < found[j].GetString("id") })
+	return found, nil
+}
+
+func (p *Provider) find(kind, id string) *record {
+	for _, r := range p.records[kind] {
+		if r.id == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func (p *Provider) remove(kind, id string) {
+	records := p.records[kind][:0]
+	for _, r := range p.records[kind] {
+		if r.id != id {
+			records = append(records, r)
+		}
+	}
+	p.records[kind] = records
+}
+
+func (p *Provider) findTaggable(id string) (*record, error) {
+	if strings.HasPrefix(id, "arn:") {
+		for _, r := range p.records["aws_user"] {
+			if r.attrs["arn"] == id {
+				return r, nil
+			}
+		}
+		return nil, apiError("NoSuchEntity", "The entity %s cannot be found", id)
+	}
+	for _, k := range kinds {
+		if p.types.TagType(k.name) == "" {
+			continue
+		}
+		if r := p.find(k.name, id); r != nil {
+			return r, nil
+		}
+	}
+	return nil, apiError("InvalidID", "The ID '%s' is not valid", id)
+}
+
+// newId returns an id like aws does: the prefix followed by 17 hexadecimal characters.
+func (p *Provider) newId(prefix string) string {
+	const hex = "0123456789abcdef"
+	b := make([]byte, 17)
+	for i := range b {
+		b[i] = hex[p.rand.Intn(len(hex))]
+	}
+	return prefix + string(b)
+}
+
+// newKey returns an iam identifier: the prefix followed by uppercase letters and digits.
+func (p *Provider) newKey(prefix string, n int) string {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = chars[p.rand.Intn(len(chars))]
+	}
+	return prefix + string(b)
+}
+
+// selected returns true when the resource type, given by its name or its tag type, is searched.
+func selected(name, tagType string, resourceTypes []string) bool {
+	if len(resourceTypes) == 0 {
+		return true
+	}
+	for _, t := range resourceTypes {
+		if t == name || t == tagType {
+			return true
+		}
+	}
+	return false
+}
+
+// apiError formats an error like the aws api errors.
+func apiError(code, format string, args ...interface{}) error {
+	return fmt.Errorf("api error %s: %s", code, fmt.Sprintf(format, args...))
+}
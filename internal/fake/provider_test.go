@@ -0,0 +1,256 @@
+package fake
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/lua"
+	glua "github.com/yuin/gopher-lua"
+)
+
+func TestNetwork(t *testing.T) {
+	RegisterTestingT(t)
+	ctx := context.TODO()
+	p := New("eu-west-1")
+
+	vpc, err := p.Create(ctx, "aws_vpc", lua.Object{"cidr": "10.0.0.0/16", "tags": lua.Object{"env": "dev"}})
+	Expect(err).To(BeNil())
+	Expect(vpc.GetString("id")).To(MatchRegexp(`^vpc-[0-9a-f]{17}$`))
+	Expect(vpc.GetString("state")).To(Equal("available"))
+	Expect(vpc.GetObject("tags")).To(Equal(map[string]interface{}{"env": "dev"}))
+	Expect(vpc.GetObject("raw")["CidrBlock"]).To(Equal("10.0.0.0/16"))
+
+	_, err = p.Create(ctx, "aws_vpc", lua.Object{"cidr": "10.0.0.0/8"})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(Equal("api error InvalidVpc.Range: The CIDR '10.0.0.0/8' is invalid."))
+
+	_, err = p.Create(ctx, "aws_vpc", lua.Object{"cidr": "10.0.0/16"})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(HavePrefix("invalid input:"))
+
+	subnet, err := p.Create(ctx, "aws_subnet", lua.Object{"vpc_id": vpc["id"], "cidr": "10.0.1.0/24"})
+	Expect(err).To(BeNil())
+	Expect(subnet.GetString("availability_zone")).To(Equal("eu-west-1a"))
+	Expect(subnet.GetString("availability_zone_id")).To(Equal("euw1-az1"))
+	Expect(subnet.GetNumber("available_ip_address_count")).To(Equal(251.0))
+	Expect(subnet.GetString("arn")).To(Equal("arn:aws:ec2:eu-west-1:123456789012:subnet/" + subnet.GetString("id")))
+
+	for cidr, code := range map[string]string{
+		"10.1.0.0/24":   "InvalidSubnet.Range",
+		"10.0.1.128/25": "InvalidSubnet.Conflict",
+	} {
+		_, err = p.Create(ctx, "aws_subnet", lua.Object{"vpc_id": vpc["id"], "cidr": cidr})
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring(code))
+	}
+
+	_, err = p.Create(ctx, "aws_subnet", lua.Object{"vpc_id": vpc["id"], "cidr": "10.0.2.0/24", "availability_zone": "us-east-1a"})
+	Expect(err).ToNot(BeNil())
+
+	_, err = p.Create(ctx, "aws_subnet", lua.Object{"vpc_id": vpc["id"], "cidr": "10.0.2.0/24", "size": 1.0})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(Equal(`invalid input: unknown key "size"`))
+
+	// the gateways are never attached: they block neither the vpc nor their own deletion.
+	igw, err := p.Create(ctx, "aws_igw", lua.Object{})
+	Expect(err).To(BeNil())
+	Expect(igw["attachments"]).To(BeEmpty())
+
+	nat, err := p.Create(ctx, "aws_nat", lua.Object{"subnet_id": subnet["id"]})
+	Expect(err).To(BeNil())
+	Expect(nat.GetString("vpc_id")).To(Equal(vpc.GetString("id")))
+
+	_, err = p.Delete(ctx, "aws_vpc", lua.Object{"id": vpc["id"]})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("DependencyViolation"))
+	_, err = p.Delete(ctx, "aws_subnet", lua.Object{"id": subnet["id"]})
+	Expect(err).ToNot(BeNil())
+
+	_, err = p.Delete(ctx, "aws_nat", lua.Object{"id": nat["id"]})
+	Expect(err).To(BeNil())
+	_, err = p.Delete(ctx, "aws_subnet", lua.Object{"id": subnet["id"]})
+	Expect(err).To(BeNil())
+	_, err = p.Delete(ctx, "aws_vpc", lua.Object{"id": vpc["id"]})
+	Expect(err).To(BeNil())
+	_, err = p.Delete(ctx, "aws_igw", lua.Object{"id": igw["id"]})
+	Expect(err).To(BeNil())
+
+	_, err = p.Delete(ctx, "aws_vpc", lua.Object{"id": vpc["id"]})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("InvalidVpcID.NotFound"))
+
+	_, err = p.Create(ctx, "aws_volume", lua.Object{"availability_zone": "eu-west-1a", "size": 1.0})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(Equal(`resource "aws_volume" does not support create`))
+}
+
+func TestDefine(t *testing.T) {
+	RegisterTestingT(t)
+	ctx := context.TODO()
+	p := New("")
+
+	// the built-in types are registered even when they are not simulated.
+	Expect(p.Define(lua.ResourceDefinition{Name: "aws_volume"})).ToNot(Succeed())
+	Expect(p.Define(lua.ResourceDefinition{Name: "aws_vpc"})).ToNot(Succeed())
+
+	update := func(ctx context.Context, o lua.Object) (lua.Object, error) { return o, nil }
+	Expect(p.Define(lua.ResourceDefinition{
+		Name:   "my_bastion",
+		Update: update,
+		Schema: lua.Object{"name": lua.Object{"type": "string", "required": true}},
+	})).To(Succeed())
+	_, err := p.Update(ctx, "my_bastion", lua.Object{"id": "b-1"})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(Equal(`invalid input: field "name" is required`))
+	_, err = p.Update(ctx, "my_bastion", lua.Object{"id": "b-1", "name": "one"})
+	Expect(err).To(BeNil())
+
+	verbs := map[string]interface{}{}
+	for _, t := range p.Types() {
+		verbs[t.GetString("name")] = t["verbs"]
+	}
+	Expect(verbs["aws_volume"]).To(BeEmpty())
+	Expect(verbs["aws_availability_zones"]).To(Equal([]string{"get", "list"}))
+	Expect(verbs["my_bastion"]).To(Equal([]string{"update"}))
+}
+
+func TestList(t *testing.T) {
+	RegisterTestingT(t)
+	ctx := context.TODO()
+	p := New("us-east-1")
+
+	dev, _ := p.Create(ctx, "aws_vpc", lua.Object{"cidr": "10.0.0.0/16", "tags": lua.Object{"env": "dev", "team": "a"}})
+	prod, _ := p.Create(ctx, "aws_vpc", lua.Object{"cidr": "10.1.0.0/16", "tags": lua.Object{"env": "prod"}})
+
+	tests := []struct {
+		name  string
+		input lua.Object
+		ids   []interface{}
+	}{
+		{name: "all", input: lua.Object{}, ids: []interface{}{dev["id"], prod["id"]}},
+		{name: "ids", input: lua.Object{"vpc_ids": []interface{}{prod["id"]}}, ids: []interface{}{prod["id"]}},
+		{name: "tag filter", input: lua.Object{"filters": []interface{}{lua.Object{"Name": "tag:env", "Values": []interface{}{"dev"}}}}, ids: []interface{}{dev["id"]}},
+		{name: "filter map", input: lua.Object{"filters": lua.Object{"cidr-block": "10.1.*"}}, ids: []interface{}{prod["id"]}},
+		{name: "tag key", input: lua.Object{"filters": lua.Object{"tag-key": "team"}}, ids: []interface{}{dev["id"]}},
+		{name: "no match", input: lua.Object{"filters": lua.Object{"state": "pending"}}, ids: []interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := p.List(ctx, "aws_vpc", tt.input)
+			Expect(err).To(BeNil())
+			ids := []interface{}{}
+			for _, item := range items {
+				ids = append(ids, item["id"])
+			}
+			Expect(ids).To(Equal(tt.ids))
+		})
+	}
+
+	zones, err := p.List(ctx, "aws_availability_zones", lua.Object{"zone_names": []interface{}{"us-east-1b"}})
+	Expect(err).To(BeNil())
+	Expect(zones).To(HaveLen(1))
+	Expect(zones[0]["id"]).To(Equal("use1-az2"))
+
+	Expect(p.Tag(ctx, prod.GetString("id"), map[string]string{"team": "b"})).To(Succeed())
+	found, err := p.FindByTag(ctx, map[string]string{"team": "b"}, []string{"aws_vpc"})
+	Expect(err).To(BeNil())
+	Expect(found).To(HaveLen(1))
+	Expect(found[0]["type"]).To(Equal("aws_vpc"))
+
+	Expect(p.Untag(ctx, prod.GetString("id"), []string{})).To(MatchError("at least one tag key is required"))
+	Expect(p.Untag(ctx, prod.GetString("id"), []string{"team"})).To(Succeed())
+	found, _ = p.FindByTag(ctx, map[string]string{"team": "b"}, nil)
+	Expect(found).To(BeEmpty())
+}
+
+func TestIam(t *testing.T) {
+	RegisterTestingT(t)
+	ctx := context.TODO()
+	p := New("")
+
+	user, err := p.Create(ctx, "aws_user", lua.Object{"username": "bob"})
+	Expect(err).To(BeNil())
+	Expect(user["id"]).To(Equal("bob"))
+	Expect(user["arn"]).To(Equal("arn:aws:iam::123456789012:user/bob"))
+
+	_, err = p.Create(ctx, "aws_user", lua.Object{"username": "bob"})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("EntityAlreadyExists"))
+
+	key, err := p.Create(ctx, "aws_access_key", lua.Object{"username": "bob"})
+	Expect(err).To(BeNil())
+	Expect(key.GetString("id")).To(MatchRegexp(`^AKIA[A-Z2-7]{16}$`))
+	Expect(key.GetString("secret_access_key")).To(HaveLen(40))
+	_, err = p.Create(ctx, "aws_access_key", lua.Object{"username": "bob"})
+	Expect(err).To(BeNil())
+	_, err = p.Create(ctx, "aws_access_key", lua.Object{"username": "bob"})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("LimitExceeded"))
+
+	keys, err := p.List(ctx, "aws_access_key", lua.Object{"username": "bob"})
+	Expect(err).To(BeNil())
+	Expect(keys).To(HaveLen(2))
+	Expect(keys[0]).ToNot(HaveKey("secret_access_key"))
+
+	_, err = p.Delete(ctx, "aws_user", lua.Object{"username": "bob"})
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("DeleteConflict"))
+
+	Expect(p.Tag(ctx, user.GetString("arn"), map[string]string{"team": "a"})).To(Succeed())
+	got, err := p.Get(ctx, "aws_user", lua.Object{"id": "bob"})
+	Expect(err).To(BeNil())
+	Expect(got.GetObject("tags")).To(Equal(map[string]interface{}{"team": "a"}))
+}
+
+func TestFixture(t *testing.T) {
+	RegisterTestingT(t)
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "fixture.json")
+
+	p := New("eu-west-1")
+	vpc, err := p.Create(ctx, "aws_vpc", lua.Object{"cidr": "10.0.0.0/16", "tags": lua.Object{"env": "dev"}})
+	Expect(err).To(BeNil())
+	Expect(p.Save(path)).To(Succeed())
+
+	loaded := New("eu-west-1")
+	Expect(loaded.Load(path)).To(Succeed())
+	got, err := loaded.Get(ctx, "aws_vpc", lua.Object{"id": vpc["id"]})
+	Expect(err).To(BeNil())
+	Expect(got).To(Equal(vpc))
+
+	// the seeded vpc is taken into account by the validation of the subnets.
+	_, err = loaded.Create(ctx, "aws_subnet", lua.Object{"vpc_id": vpc["id"], "cidr": "10.1.0.0/24"})
+	Expect(err).ToNot(BeNil())
+}
+
+func TestLuaModule(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := glua.NewState()
+	defer L.Close()
+	L.PreloadModule("aws", lua.NewAwsModule(New("eu-west-1")).Loader)
+
+	script := `
+local aws = require("aws")
+
+local vpc, err = aws.create("aws_vpc", { cidr = "10.0.0.0/16", tags = { Name = "main" } })
+assert(err == nil, err)
+
+local _, err = aws.create("aws_subnet", { vpc_id = vpc.id, cidr = "10.0.1.0/24" })
+assert(err == nil, err)
+
+local subnets, err = aws.list("aws_subnet", { filters = { { Name = "vpc-id", Values = { vpc.id } } } })
+assert(err == nil, err)
+assert(#subnets == 1)
+assert(subnets[1].cidr_block == "10.0.1.0/24")
+
+local found = aws.find_by_tag({ Name = "main" })
+assert(found[1].id == vpc.id)
+
+local _, err = aws.delete("aws_vpc", { id = vpc.id })
+assert(err ~= nil)
+`
+	Expect(L.DoString(script)).To(Succeed())
+}
@@ -0,0 +1,678 @@
+package fake
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+// kind describes a simulated resource type.
+type kind struct {
+	name string
+	// idAttr, arnAttr and stateAttr are the attributes rendered as id, arn and state like the aws provider does.
+	idAttr    string
+	arnAttr   string
+	stateAttr string
+	// fields lists the attributes accepted on create.
+	fields  []string
+	aliases map[string]string
+	// notFoundCode and notFoundMessage format the error of a missing resource. The message takes the id.
+	notFoundCode    string
+	notFoundMessage string
+	// hideOnList is an attribute returned only on create (e.g. the secret of an access key).
+	hideOnList string
+	create     func(p *Provider, in lua.Object) (lua.Object, error)
+	// conflict returns an error when the resource cannot be deleted.
+	conflict func(p *Provider, r *record) error
+}
+
+var kinds = []*kind{
+	{
+		name:            "aws_vpc",
+		idAttr:          "vpc_id",
+		stateAttr:       "state",
+		fields:          []string{"cidr_block", "ipv4_ipam_pool_id", "amazon_provided_ipv6_cidr_block", "instance_tenancy"},
+		aliases:         map[string]string{"cidr": "cidr_block"},
+		notFoundCode:    "InvalidVpcID.NotFound",
+		notFoundMessage: "The vpc ID '%s' does not exist",
+		create:          createVpc,
+		conflict:        vpcConflict,
+	},
+	{
+		name:            "aws_subnet",
+		idAttr:          "subnet_id",
+		arnAttr:         "subnet_arn",
+		stateAttr:       "state",
+		fields:          []string{"vpc_id", "cidr_block", "ipv6_cidr_block", "ipv4_ipam_pool_id", "availability_zone", "availability_zone_id"},
+		aliases:         map[string]string{"cidr": "cidr_block"},
+		notFoundCode:    "InvalidSubnetID.NotFound",
+		notFoundMessage: "The subnet ID '%s' does not exist",
+		create:          createSubnet,
+		conflict:        subnetConflict,
+	},
+	{
+		name:            "aws_igw",
+		idAttr:          "internet_gateway_id",
+		notFoundCode:    "InvalidInternetGatewayID.NotFound",
+		notFoundMessage: "The internetGateway ID '%s' does not exist",
+		create:          createInternetGateway,
+	},
+	{
+		name:            "aws_nat",
+		idAttr:          "nat_gateway_id",
+		stateAttr:       "state",
+		fields:          []string{"subnet_id", "allocation_id", "connectivity_type"},
+		notFoundCode:    "NatGatewayNotFound",
+		notFoundMessage: "The Nat Gateway %s was not found",
+		create:          createNatGateway,
+	},
+	{
+		name:            "aws_availability_zones",
+		idAttr:          "zone_id",
+		stateAttr:       "state",
+		notFoundCode:    "InvalidParameterValue",
+		notFoundMessage: "Invalid availability zone: [%s]",
+	},
+	{
+		name:            "aws_user",
+		idAttr:          "user_name",
+		arnAttr:         "arn",
+		fields:          []string{"user_name", "path", "permissions_boundary"},
+		aliases:         map[string]string{"username": "user_name"},
+		notFoundCode:    "NoSuchEntity",
+		notFoundMessage: "The user with name %s cannot be found.",
+		create:          createUser,
+		conflict:        userConflict,
+	},
+	{
+		name:            "aws_access_key",
+		idAttr:          "access_key_id",
+		stateAttr:       "status",
+		fields:          []string{"user_name"},
+		aliases:         map[string]string{"username": "user_name"},
+		notFoundCode:    "NoSuchEntity",
+		notFoundMessage: "The Access Key with id %s cannot be found.",
+		hideOnList:      "secret_access_key",
+		create:          createAccessKey,
+	},
+}
+
+// lookup returns the simulated kind. Types of the aws provider which are not simulated have their own error.
+func lookup(name string) (*kind, error) {
+	for _, k := range kinds {
+		if k.name == name {
+			return k, nil
+		}
+	}
+	if strings.HasPrefix(name, "aws_") {
+		return nil, fmt.Errorf("resource %q is not simulated by the fake provider", name)
+	}
+	return nil, fmt.Errorf("unknown resource %q", name)
+}
+
+// input converts the keys of the input into the snake_case attributes of the kind.
+// "id" is the id attribute of the kind.
+func (k *kind) input(o lua.Object) (lua.Object, error) {
+	in := make(lua.Object, len(o))
+	for key, v := range o {
+		name := snakeCase(key)
+		if alias, found := k.aliases[name]; found {
+			name = alias
+		}
+		if name == "id" {
+			name = k.idAttr
+		}
+		if _, found := in[name]; found {
+			return nil, fmt.Errorf("invalid input: key %q is set twice", name)
+		}
+		in[name] = v
+	}
+	return in, nil
+}
+
+// checkFields rejects the attributes which are not accepted on create.
+func (k *kind) checkFields(in lua.Object) error {
+	accepted := map[string]bool{"tags": true, "tag_specifications": true, "dry_run": true}
+	for _, f := range k.fields {
+		accepted[f] = true
+	}
+	keys := make([]string, 0, len(in))
+	for key := range in {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !accepted[key] {
+			return fmt.Errorf("invalid input: unknown key %q", key)
+		}
+	}
+	return nil
+}
+
+// render returns the record in the normalized shape of the aws provider: {id, arn, state, tags, <attrs>, raw}.
+func (k *kind) render(r *record) lua.Object {
+	o := make(lua.Object, len(r.attrs)+4)
+	for key, v := range r.attrs {
+		o[key] = v
+	}
+	o["id"] = r.id
+	if k.arnAttr != "" {
+		o["arn"] = r.attrs[k.arnAttr]
+	}
+	if k.stateAttr != "" {
+		o["state"] = r.attrs[k.stateAttr]
+	}
+	o["tags"] = tagMap(r.tags)
+
+	raw := pascalCaseKeys(r.attrs).(lua.Object)
+	keys := make([]string, 0, len(r.tags))
+	for key := range r.tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tags := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		tags = append(tags, lua.Object{"Key": key, "Value": r.tags[key]})
+	}
+	raw["Tags"] = tags
+	o["raw"] = raw
+	return o
+}
+
+func createVpc(p *Provider, in lua.Object) (lua.Object, error) {
+	cidr := in.GetString("cidr_block")
+	if cidr == "" {
+		return nil, fmt.Errorf("ipam pools are not simulated by the fake provider")
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil || prefix.Bits() < 16 || prefix.Bits() > 28 || prefix.Masked() != prefix {
+		return nil, apiError("InvalidVpc.Range", "The CIDR '%s' is invalid.", cidr)
+	}
+
+	tenancy := in.GetString("instance_tenancy")
+	if tenancy == "" {
+		tenancy = "default"
+	}
+	return lua.Object{
+		"vpc_id":           p.newId("vpc-"),
+		"cidr_block":       cidr,
+		"state":            "available",
+		"instance_tenancy": tenancy,
+		"is_default":       false,
+		"owner_id":         accountId,
+	}, nil
+}
+
+func createSubnet(p *Provider, in lua.Object) (lua.Object, error) {
+	vpcId := in.GetString("vpc_id")
+	vpc := p.find("aws_vpc", vpcId)
+	if vpc == nil {
+		return nil, apiError("InvalidVpcID.NotFound", "The vpc ID '%s' does not exist", vpcId)
+	}
+
+	cidr := in.GetString("cidr_block")
+	if cidr == "" {
+		return nil, fmt.Errorf("only IPv4 subnets are simulated by the fake provider")
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil || prefix.Bits() < 16 || prefix.Bits() > 28 || prefix.Masked() != prefix {
+		return nil, apiError("InvalidSubnet.Range", "The CIDR '%s' is invalid.", cidr)
+	}
+	vpcPrefix := netip.MustParsePrefix(vpc.attrs.GetString("cidr_block"))
+	if prefix.Bits() < vpcPrefix.Bits() || !vpcPrefix.Contains(prefix.Addr()) {
+		return nil, apiError("InvalidSubnet.Range", "The CIDR '%s' is invalid.", cidr)
+	}
+	for _, s := range p.records["aws_subnet"] {
+		if s.attrs.GetString("vpc_id") != vpcId {
+			continue
+		}
+		if other, err := netip.ParsePrefix(s.attrs.GetString("cidr_block")); err == nil && other.Overlaps(prefix) {
+			return nil, apiError("InvalidSubnet.Conflict", "The CIDR '%s' conflicts with another subnet", cidr)
+		}
+	}
+
+	az, err := p.availabilityZone(in.GetString("availability_zone"), in.GetString("availability_zone_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	id := p.newId("subnet-")
+	return lua.Object{
+		"subnet_id":  id,
+		"subnet_arn": fmt.Sprintf("arn:aws:ec2:%s:%s:subnet/%s", p.region, accountId, id),
+		"vpc_id":     vpcId,
+		"cidr_block": cidr,
+		// aws reserves 5 addresses in every subnet.
+		"available_ip_address_count": float64(uint64(1)<<(32-prefix.Bits()) - 5),
+		"availability_zone":          az.GetString("zone_name"),
+		"availability_zone_id":       az.GetString("zone_id"),
+		"state":                      "available",
+		"default_for_az":             false,
+		"map_public_ip_on_launch":    false,
+		"owner_id":                   accountId,
+	}, nil
+}
+
+// createInternetGateway returns a detached gateway. The aws provider cannot attach a gateway to a VPC, so the
+// gateways stay detached: they never prevent the deletion of a VPC and can always be deleted.
+func createInternetGateway(p *Provider, in lua.Object) (lua.Object, error) {
+	return lua.Object{
+		"internet_gateway_id": p.newId("igw-"),
+		"attachments":         []interface{}{},
+		"owner_id":            accountId,
+	}, nil
+}
+
+func createNatGateway(p *Provider, in lua.Object) (lua.Object, error) {
+	subnetId := in.GetString("subnet_id")
+	subnet := p.find("aws_subnet", subnetId)
+	if subnet == nil {
+		return nil, apiError("InvalidSubnetID.NotFound", "The subnet ID '%s' does not exist", subnetId)
+	}
+
+	connectivity := in.GetString("connectivity_type")
+	if connectivity == "" {
+		connectivity = "public"
+	}
+	o := lua.Object{
+		"nat_gateway_id":    p.newId("nat-"),
+		"subnet_id":         subnetId,
+		"vpc_id":            subnet.attrs.GetString("vpc_id"),
+		"connectivity_type": connectivity,
+		"state":             "available",
+		"create_time":       now(),
+	}
+	if allocation := in.GetString("allocation_id"); allocation != "" {
+		o["nat_gateway_addresses"] = []interface{}{lua.Object{"allocation_id": allocation}}
+	}
+	return o, nil
+}
+
+func createUser(p *Provider, in lua.Object) (lua.Object, error) {
+	name := in.GetString("user_name")
+	if p.find("aws_user", name) != nil {
+		return nil, apiError("EntityAlreadyExists", "User with name %s already exists.", name)
+	}
+
+	userPath := in.GetString("path")
+	if userPath == "" {
+		userPath = "/"
+	}
+	o := lua.Object{
+		"user_name":   name,
+		"user_id":     p.newKey("AIDA", 17),
+		"arn":         fmt.Sprintf("arn:aws:iam::%s:user%s%s", accountId, userPath, name),
+		"path":        userPath,
+		"create_date": now(),
+	}
+	if boundary := in.GetString("permissions_boundary"); boundary != "" {
+		o["permissions_boundary"] = lua.Object{
+			"permissions_boundary_arn":  boundary,
+			"permissions_boundary_type": "Policy",
+		}
+	}
+	return o, nil
+}
+
+func createAccessKey(p *Provider, in lua.Object) (lua.Object, error) {
+	name := in.GetString("user_name")
+	if name == "" {
+		return nil, fmt.Errorf(`invalid input: field "user_name" is required by the fake provider`)
+	}
+	if p.find("aws_user", name) == nil {
+		return nil, apiError("NoSuchEntity", "The user with name %s cannot be found.", name)
+	}
+	if len(p.accessKeys(name)) >= 2 {
+		return nil, apiError("LimitExceeded", "Cannot exceed quota for AccessKeysPerUser: 2")
+	}
+
+	secret := make([]byte, 40)
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	for i := range secret {
+		secret[i] = chars[p.rand.Intn(len(chars))]
+	}
+	return lua.Object{
+		"access_key_id":     p.newKey("AKIA", 16),
+		"secret_access_key": string(secret),
+		"user_name":         name,
+		"status":            "Active",
+		"create_date":       now(),
+	}, nil
+}
+
+func vpcConflict(p *Provider, r *record) error {
+	for _, s := range p.records["aws_subnet"] {
+		if s.attrs.GetString("vpc_id") == r.id {
+			return apiError("DependencyViolation", "The vpc '%s' has dependencies and cannot be deleted.", r.id)
+		}
+	}
+	return nil
+}
+
+func subnetConflict(p *Provider, r *record) error {
+	for _, nat := range p.records["aws_nat"] {
+		if nat.attrs.GetString("subnet_id") == r.id {
+			return apiError("DependencyViolation", "The subnet '%s' has dependencies and cannot be deleted.", r.id)
+		}
+	}
+	return nil
+}
+
+func userConflict(p *Provider, r *record) error {
+	if len(p.accessKeys(r.id)) > 0 {
+		return apiError("DeleteConflict", "Cannot delete entity, must delete access keys first.")
+	}
+	return nil
+}
+
+func (p *Provider) accessKeys(user string) []*record {
+	keys := []*record{}
+	for _, r := range p.records["aws_access_key"] {
+		if r.attrs.GetString("user_name") == user {
+			keys = append(keys, r)
+		}
+	}
+	return keys
+}
+
+// directions abbreviates the directions of the region names in the zone ids: eu-west-1 -> euw1-az1.
+var directions = map[string]string{
+	"north":     "n",
+	"south":     "s",
+	"east":      "e",
+	"west":      "w",
+	"central":   "c",
+	"northeast": "ne",
+	"southeast": "se",
+	"northwest": "nw",
+	"southwest": "sw",
+}
+
+func (p *Provider) seedAvailabilityZones() {
+	parts := strings.Split(p.region, "-")
+	abbrev := p.region
+	if len(parts) == 3 {
+		direction, found := directions[parts[1]]
+		if !found {
+			direction = parts[1][:1]
+		}
+		abbrev = parts[0] + direction + parts[2]
+	}
+
+	zones := make([]*record, 0, 3)
+	for i, suffix := range []string{"a", "b", "c"} {
+		id := fmt.Sprintf("%s-az%d", abbrev, i+1)
+		zones = append(zones, &record{
+			id: id,
+			attrs: lua.Object{
+				"zone_id":     id,
+				"zone_name":   p.region + suffix,
+				"region_name": p.region,
+				"zone_type":   "availability-zone",
+				"state":       "available",
+			},
+			tags: map[string]string{},
+		})
+	}
+	p.records["aws_availability_zones"] = zones
+}
+
+// availabilityZone returns the zone by name or id. The first zone is returned when both are empty.
+func (p *Provider) availabilityZone(name, id string) (lua.Object, error) {
+	zones := p.records["aws_availability_zones"]
+	names := make([]string, 0, len(zones))
+	for _, z := range zones {
+		if (name == "" && id == "") || z.attrs.GetString("zone_name") == name || z.id == id {
+			return z.attrs, nil
+		}
+		names = append(names, z.attrs.GetString("zone_name"))
+	}
+	value := name
+	if value == "" {
+		value = id
+	}
+	return nil, apiError("InvalidParameterValue",
+		"Value (%s) for parameter availabilityZone is invalid. Subnets can currently only be created in the following availability zones: %s.",
+		value, strings.Join(names, ", "))
+}
+
+// inputTags returns the tags of the input: either a map "tags" or the tags of "tag_specifications".
+func inputTags(in lua.Object) (map[string]string, error) {
+	tags := map[string]string{}
+	switch t := in["tags"].(type) {
+	case nil:
+	case lua.Object:
+		for k, v := range t {
+			tags[k] = fmt.Sprint(v)
+		}
+	default:
+		return nil, fmt.Errorf("invalid input: tags must be a table")
+	}
+
+	specs, _ := in["tag_specifications"].([]interface{})
+	for _, s := range specs {
+		spec, ok := s.(lua.Object)
+		if !ok {
+			continue
+		}
+		list, _ := spec["Tags"].([]interface{})
+		if list == nil {
+			list, _ = spec["tags"].([]interface{})
+		}
+		for _, item := range list {
+			if tag, ok := item.(lua.Object); ok {
+				tags[tag.GetString("Key")] = tag.GetString("Value")
+			}
+		}
+	}
+	return tags, nil
+}
+
+// ignoredListKeys are the keys of the list inputs which do not filter resources.
+var ignoredListKeys = map[string]bool{
+	"dry_run":     true,
+	"max_results": true,
+	"next_token":  true,
+	"marker":      true,
+	"max_items":   true,
+}
+
+// newMatcher returns a function matching the rendered resources with the filters and the attributes of the input.
+// Filters are either a list of {Name, Values} or a map {name = values}. Their values may contain * and ? wildcards.
+// Keys like subnet_ids or zone_names match the singular attribute, the other keys must be equal.
+func newMatcher(in lua.Object) (func(o lua.Object) bool, error) {
+	type filter struct {
+		name   string
+		values []string
+	}
+
+	filters := []filter{}
+	for key, v := range in {
+		switch {
+		case ignoredListKeys[key]:
+		case key == "filters":
+			switch f := v.(type) {
+			case []interface{}:
+				for _, item := range f {
+					o, ok := item.(lua.Object)
+					if !ok {
+						return nil, fmt.Errorf("invalid input: filters must be a list of {Name, Values}")
+					}
+					name := o.GetString("Name")
+					values := o["Values"]
+					if name == "" {
+						name, values = o.GetString("name"), o["values"]
+					}
+					filters = append(filters, filter{name: name, values: toStrings(values)})
+				}
+			case lua.Object:
+				for name, values := range f {
+					filters = append(filters, filter{name: name, values: toStrings(values)})
+				}
+			default:
+				return nil, fmt.Errorf("invalid input: filters must be a list of {Name, Values}")
+			}
+		case key == "path_prefix":
+			filters = append(filters, filter{name: "path", values: []string{fmt.Sprint(v) + "*"}})
+		case strings.HasSuffix(key, "_ids") || strings.HasSuffix(key, "_names"):
+			filters = append(filters, filter{name: strings.TrimSuffix(key, "s"), values: toStrings(v)})
+		default:
+			filters = append(filters, filter{name: key, values: toStrings(v)})
+		}
+	}
+
+	return func(o lua.Object) bool {
+		for _, f := range filters {
+			if !matchAny(filterValues(o, f.name), f.values) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// filterValues returns the values of the resource designated by the name of a filter.
+func filterValues(o lua.Object, name string) []string {
+	tags, _ := o["tags"].(lua.Object)
+	if key := strings.TrimPrefix(name, "tag:"); key != name {
+		if v, found := tags[key]; found {
+			return []string{fmt.Sprint(v)}
+		}
+		return nil
+	}
+	if name == "tag-key" {
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		return keys
+	}
+
+	name = strings.ReplaceAll(name, "-", "_")
+	// nested attributes like attachment.vpc-id are looked up in every element of the list.
+	if parent, child, nested := strings.Cut(name, "."); nested {
+		list, _ := o[parent].([]interface{})
+		if list == nil {
+			list, _ = o[parent+"s"].([]interface{})
+		}
+		values := []string{}
+		for _, item := range list {
+			if e, ok := item.(lua.Object); ok && e[child] != nil {
+				values = append(values, fmt.Sprint(e[child]))
+			}
+		}
+		return values
+	}
+	if v, found := o[name]; found && v != nil {
+		return []string{fmt.Sprint(v)}
+	}
+	return nil
+}
+
+func matchAny(actual, patterns []string) bool {
+	for _, a := range actual {
+		for _, p := range patterns {
+			if wildcard(p).MatchString(a) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wildcard compiles a filter value where * matches any characters and ? a single one, like aws does.
+func wildcard(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func toStrings(v interface{}) []string {
+	switch list := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		s := make([]string, 0, len(list))
+		for _, item := range list {
+			s = append(s, fmt.Sprint(item))
+		}
+		return s
+	}
+	return []string{fmt.Sprint(v)}
+}
+
+func tagMap(tags map[string]string) lua.Object {
+	m := make(lua.Object, len(tags))
+	for k, v := range tags {
+		m[k] = v
+	}
+	return m
+}
+
+// snakeCase converts a sdk field name into its snake_case form like the decoder of the aws provider.
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) && runes[i-1] != '_' {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// pascalCase converts an attribute into the name of the sdk field: cidr_block -> CidrBlock.
+func pascalCase(s string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(s, "_") {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func pascalCaseKeys(v interface{}) interface{} {
+	switch value := v.(type) {
+	case lua.Object:
+		o := make(lua.Object, len(value))
+		for k, item := range value {
+			o[pascalCase(k)] = pascalCaseKeys(item)
+		}
+		return o
+	case []interface{}:
+		list := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			list = append(list, pascalCaseKeys(item))
+		}
+		return list
+	}
+	return v
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
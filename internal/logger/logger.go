@@ -0,0 +1,247 @@
+// Package logger writes leveled logs as logfmt text or json lines.
+//
+// A log is a message followed by key/value pairs:
+//
+//	logger.Default().Info("vpc created", "id", id, "cidr", cidr)
+//
+// Values whose key names a secret (secret, password, session token, access key, key material) are
+// replaced by REDACTED, in nested maps and lists too. Structs are redacted by their json encoding.
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveKeys are the parts of the keys, lower-cased without "_" and "-", whose values are redacted.
+var sensitiveKeys = []string{"secret", "password", "sessiontoken", "securitytoken", "accesskey", "keymaterial"}
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levels = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levels[l]
+}
+
+// ParseLevel parses debug, info, warn or error.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levels {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", s)
+}
+
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	level  Level
+	json   bool
+	fields []interface{}
+	now    func() time.Time
+}
+
+var defaultLogger = &Logger{mu: &sync.Mutex{}, out: os.Stderr, level: LevelInfo, now: time.Now}
+
+// Default returns the logger shared by the providers and the lua modules. It writes text to stderr
+// from the info level until it is replaced by SetDefault.
+func Default() *Logger {
+	return defaultLogger
+}
+
+func SetDefault(l *Logger) {
+	defaultLogger = l
+}
+
+// New returns a logger writing the logs from the level in the format "text" or "json".
+func New(out io.Writer, level Level, format string) (*Logger, error) {
+	if format != "text" && format != "json" {
+		return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+	return &Logger{mu: &sync.Mutex{}, out: out, level: level, json: format == "json", now: time.Now}, nil
+}
+
+// With returns a logger which adds the key/value pairs to every log.
+func (l *Logger) With(fields ...interface{}) *Logger {
+	c := *l
+	c.fields = append(append([]interface{}{}, l.fields...), fields...)
+	return &c
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *Logger) Debug(msg string, fields ...interface{}) {
+	l.Log(LevelDebug, msg, fields...)
+}
+
+func (l *Logger) Info(msg string, fields ...interface{}) {
+	l.Log(LevelInfo, msg, fields...)
+}
+
+func (l *Logger) Warn(msg string, fields ...interface{}) {
+	l.Log(LevelWarn, msg, fields...)
+}
+
+func (l *Logger) Error(msg string, fields ...interface{}) {
+	l.Log(LevelError, msg, fields...)
+}
+
+// Log writes the message with the key/value pairs if the level is enabled.
+func (l *Logger) Log(level Level, msg string, fields ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	pairs := []interface{}{"time", l.now().UTC().Format("2006-01-02T15:04:05.000Z07:00"), "level", level.String(), "msg", msg}
+	all := append(append([]interface{}{}, l.fields...), fields...)
+	for i := 0; i < len(all); i += 2 {
+		key := fmt.Sprint(all[i])
+		var value interface{}
+		if i+1 < len(all) {
+			value = all[i+1]
+		}
+		pairs = append(pairs, key, redactValue(key, value))
+	}
+
+	var buf bytes.Buffer
+	if l.json {
+		writeJSON(&buf, pairs)
+	} else {
+		writeText(&buf, pairs)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(buf.Bytes())
+}
+
+// Redact returns the value with the values of the sensitive keys of its maps replaced by REDACTED.
+// Structs are converted to maps and lists by their json encoding.
+func Redact(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil, bool, string, float64, int, int64, time.Duration:
+		return value
+	case error:
+		return value.Error()
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[k] = redactValue(k, item)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			list = append(list, Redact(item))
+		}
+		return list
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return fmt.Sprint(v)
+	}
+	return Redact(generic)
+}
+
+// redactValue redacts the whole value of a sensitive key, whatever its type. The values of the other keys are
+// redacted recursively.
+func redactValue(key string, v interface{}) interface{} {
+	if v == nil || !isSensitive(key) {
+		return Redact(v)
+	}
+	return redacted
+}
+
+func isSensitive(key string) bool {
+	k := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(buf *bytes.Buffer, pairs []interface{}) {
+	buf.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(encodeJSON(pairs[i]))
+		buf.WriteByte(':')
+		buf.Write(encodeJSON(pairs[i+1]))
+	}
+	buf.WriteString("}\n")
+}
+
+func writeText(buf *bytes.Buffer, pairs []interface{}) {
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(fmt.Sprint(pairs[i]))
+		buf.WriteByte('=')
+		buf.WriteString(textValue(pairs[i+1]))
+	}
+	buf.WriteByte('\n')
+}
+
+// textValue formats the value of a text log. Maps and lists are written in json.
+func textValue(v interface{}) string {
+	var s string
+	switch value := v.(type) {
+	case string:
+		s = value
+	case map[string]interface{}, []interface{}:
+		s = string(encodeJSON(value))
+	case time.Duration:
+		s = value.String()
+	default:
+		s = fmt.Sprint(value)
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\n\t") {
+		return string(encodeJSON(s))
+	}
+	return s
+}
+
+func encodeJSON(v interface{}) []byte {
+	if d, ok := v.(time.Duration); ok {
+		v = d.String()
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return encodeJSON(fmt.Sprint(v))
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestText(t *testing.T) {
+	gomega.RegisterTestingT(t)
+
+	var out bytes.Buffer
+	l, err := New(&out, LevelInfo, "text")
+	gomega.Expect(err).To(gomega.BeNil())
+	l.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	l.Debug("not written")
+	l.With("run", 1).Info("vpc created", "id", "vpc-1", "cidr", "10.0.0.0/16", "note", "two words", "empty", "")
+	l.Warn("call failed", "error", errors.New(`api error: "bad"`), "duration", 1500*time.Millisecond)
+	gomega.Expect(out.String()).To(gomega.Equal(`time=2024-01-02T03:04:05.000Z level=info msg="vpc created" run=1 id=vpc-1 cidr=10.0.0.0/16 note="two words" empty=""
+time=2024-01-02T03:04:05.000Z level=warn msg="call failed" error="api error: \"bad\"" duration=1.5s
+`))
+}
+
+func TestJSON(t *testing.T) {
+	gomega.RegisterTestingT(t)
+
+	var out bytes.Buffer
+	l, err := New(&out, LevelDebug, "json")
+	gomega.Expect(err).To(gomega.BeNil())
+	l.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	l.Debug("key created", "key", map[string]interface{}{"user_name": "bob", "access_key_id": "AKIA1", "SecretAccessKey": "s3cr3t"}, "password", "p4ss")
+	gomega.Expect(out.String()).To(gomega.Equal(`{"time":"2024-01-02T03:04:05.000Z","level":"debug","msg":"key created","key":{"SecretAccessKey":"REDACTED","access_key_id":"REDACTED","user_name":"bob"},"password":"REDACTED"}
+`))
+
+	_, err = New(&out, LevelDebug, "xml")
+	gomega.Expect(err).ToNot(gomega.BeNil())
+}
+
+func TestRedact(t *testing.T) {
+	gomega.RegisterTestingT(t)
+
+	type accessKey struct {
+		UserName        string
+		SecretAccessKey *string
+	}
+	secret := "s3cr3t"
+	gomega.Expect(Redact([]interface{}{accessKey{UserName: "bob", SecretAccessKey: &secret}})).To(gomega.Equal([]interface{}{
+		map[string]interface{}{"UserName": "bob", "SecretAccessKey": "REDACTED"},
+	}))
+	gomega.Expect(Redact(map[string]interface{}{"session_token": nil, "NextToken": "abc"})).To(gomega.Equal(map[string]interface{}{"session_token": nil, "NextToken": "abc"}))
+	// the values of the sensitive keys are redacted whole, lists and maps included.
+	gomega.Expect(Redact(map[string]interface{}{
+		"secrets":  []interface{}{"s3cr3t", map[string]interface{}{"value": "p4ss"}},
+		"password": map[string]interface{}{"value": "p4ss"},
+		"names":    []interface{}{"bob"},
+	})).To(gomega.Equal(map[string]interface{}{"secrets": "REDACTED", "password": "REDACTED", "names": []interface{}{"bob"}}))
+
+	level, err := ParseLevel("WARN")
+	gomega.Expect(err).To(gomega.BeNil())
+	gomega.Expect(level).To(gomega.Equal(LevelWarn))
+	_, err = ParseLevel("verbose")
+	gomega.Expect(err).ToNot(gomega.BeNil())
+}
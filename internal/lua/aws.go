@@ -2,6 +2,7 @@ package lua
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 
@@ -10,29 +11,91 @@ import (
 
 type AwsProvider interface {
 	Create(ctx context.Context, resource string, o Object) (Object, error)
+	Get(ctx context.Context, resource string, o Object) (Object, error)
+	Update(ctx context.Context, resource string, o Object) (Object, error)
 	Delete(ctx context.Context, resource string, o Object) (Object, error)
-	List(ctx context.Context, resource string, o Object) (Object, error)
+	List(ctx context.Context, resource string, o Object) ([]Object, error)
+	Call(ctx context.Context, service, operation string, o Object) (Object, error)
+	Tag(ctx context.Context, id string, tags map[string]string) error
+	Untag(ctx context.Context, id string, keys []string) error
+	FindByTag(ctx context.Context, tags map[string]string, resourceTypes []string) ([]Object, error)
+	Types() []Object
+	Define(def ResourceDefinition) error
+	// ResourceType returns the resource type of an id accepted by Tag and Untag.
+	ResourceType(id string) (string, error)
+}
+
+// ResourceHandler runs a verb of a resource type.
+type ResourceHandler = func(ctx context.Context, o Object) (Object, error)
+
+// ResourceDefinition is a resource type implemented outside of the provider, e.g. by lua functions.
+// Verbs without a handler are not supported by the type.
+type ResourceDefinition struct {
+	Name      string
+	Create    ResourceHandler
+	Read      ResourceHandler
+	Update    ResourceHandler
+	Delete    ResourceHandler
+	List      func(ctx context.Context, o Object) ([]Object, error)
+	Schema    Object
+	DependsOn []string
 }
 
 type LuaInterpreter struct {
 	awsProvider AwsProvider
+	outputs     *Outputs
 }
 
 func NewAwsModule(awsProvider AwsProvider) *LuaInterpreter {
-	return &LuaInterpreter{awsProvider}
+	return &LuaInterpreter{awsProvider: awsProvider, outputs: NewOutputs()}
+}
+
+// Outputs returns the outputs set by the script.
+func (l *LuaInterpreter) Outputs() *Outputs {
+	return l.outputs
 }
 
 func (l *LuaInterpreter) Loader(L *lua.LState) int {
 	mod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
 		"create": l.create,
+		"get":    l.get,
+		"update": l.update,
 		"delete": l.delete,
 		"list":   l.list,
+		"call":   l.call,
+		"tag":    l.tag,
+		"untag":  l.untag,
+		"types":  l.types,
+		"define": l.define,
+		"output": l.output,
+
+		"find_by_tag": l.findByTag,
+	})
+
+	ec2Mod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"latest_image": l.latestImage,
 	})
+	L.SetField(mod, "ec2", ec2Mod)
 
 	L.Push(mod)
 	return 1
 }
 
+// output(name, value, [{sensitive = bool}]) sets an output written at the end of the run.
+func (l *LuaInterpreter) output(L *lua.LState) int {
+	name := L.CheckString(1)
+	if name == "" {
+		L.ArgError(1, "output name is empty")
+	}
+	value := toGoValue(L.Get(2))
+	if !isData(value) {
+		L.ArgError(2, "outputs can only hold strings, numbers, booleans and tables of them")
+	}
+	opts := L.OptTable(3, L.NewTable())
+	l.outputs.Set(name, value, lua.LVAsBool(opts.RawGetString("sensitive")))
+	return 0
+}
+
 func (l *LuaInterpreter) create(L *lua.LState) int {
 	respTable := L.NewTable()
 
@@ -62,6 +125,66 @@ func (l *LuaInterpreter) create(L *lua.LState) int {
 	return 1
 }
 
+// get returns one resource.
+// Input: resource type and {id = ...}
+func (l *LuaInterpreter) get(L *lua.LState) int {
+	respTable := L.NewTable()
+
+	resource, err := getData[string](L, 1)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	obj, err := getData[Object](L, 2)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	o, err := l.execute("get", resource, obj)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	respTable = toLTable(o)
+	L.Push(respTable)
+	return 1
+}
+
+func (l *LuaInterpreter) update(L *lua.LState) int {
+	respTable := L.NewTable()
+
+	resource, err := getData[string](L, 1)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	obj, err := getData[Object](L, 2)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	o, err := l.execute("update", resource, obj)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	respTable = toLTable(o)
+	L.Push(respTable)
+	return 1
+}
+
 func (l *LuaInterpreter) delete(L *lua.LState) int {
 	respTable := L.NewTable()
 
@@ -107,7 +230,45 @@ func (l *LuaInterpreter) list(L *lua.LState) int {
 		return 2
 	}
 
-	o, err := l.execute("list", resource, obj)
+	items, err := l.awsProvider.List(context.TODO(), resource, obj)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	respTable = toLList(items)
+	L.Push(respTable)
+	return 1
+}
+
+// call calls any operation of a service client by its name.
+// Input: service name ("ec2" or "iam"), operation name (e.g. "DescribeRouteTables") and the input table.
+func (l *LuaInterpreter) call(L *lua.LState) int {
+	respTable := L.NewTable()
+
+	service, err := getData[string](L, 1)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	operation, err := getData[string](L, 2)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	obj, err := getData[Object](L, 3)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	o, err := l.awsProvider.Call(context.TODO(), service, operation, obj)
 	if err != nil {
 		L.Push(respTable)
 		L.Push(lua.LString(err.Error()))
@@ -119,6 +280,270 @@ func (l *LuaInterpreter) list(L *lua.LState) int {
 	return 1
 }
 
+// types returns the registered resource types {name, verbs, depends_on}.
+func (l *LuaInterpreter) types(L *lua.LState) int {
+	L.Push(toLList(l.awsProvider.Types()))
+	return 1
+}
+
+// define registers a resource type implemented by lua functions.
+// Input: name of the type and {create = fn, read = fn, update = fn, delete = fn, list = fn, schema = {...}, depends_on = {...}}
+// Every function takes the input table and returns the resource (a list for list) or nil and an error.
+func (l *LuaInterpreter) define(L *lua.LState) int {
+	respTable := L.NewTable()
+
+	name, err := getData[string](L, 1)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	obj, err := getData[Object](L, 2)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	def := ResourceDefinition{
+		Name:   name,
+		Schema: obj.GetObject("schema"),
+	}
+	for _, d := range obj.GetList("depends_on") {
+		def.DependsOn = append(def.DependsOn, fmt.Sprint(d))
+	}
+	handlers := map[string]*ResourceHandler{
+		"create": &def.Create,
+		"read":   &def.Read,
+		"update": &def.Update,
+		"delete": &def.Delete,
+	}
+	for verb, handler := range handlers {
+		if fn, ok := obj[verb].(*lua.LFunction); ok {
+			*handler = luaHandler(L, fn)
+		}
+	}
+	if fn, ok := obj["list"].(*lua.LFunction); ok {
+		def.List = luaListHandler(L, fn)
+	}
+
+	if err := l.awsProvider.Define(def); err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(respTable)
+	return 1
+}
+
+// luaHandler wraps a lua function into a resource handler.
+func luaHandler(L *lua.LState, fn *lua.LFunction) ResourceHandler {
+	return func(ctx context.Context, o Object) (Object, error) {
+		ret, err := callLuaHandler(L, fn, o)
+		if err != nil {
+			return Object{}, err
+		}
+		switch v := ret.(type) {
+		case nil:
+			return Object{}, nil
+		case Object:
+			return v, nil
+		}
+		return Object{}, fmt.Errorf("expected table. got: %+v", ret)
+	}
+}
+
+// luaListHandler wraps a lua function returning a list into a list handler.
+func luaListHandler(L *lua.LState, fn *lua.LFunction) func(ctx context.Context, o Object) ([]Object, error) {
+	return func(ctx context.Context, o Object) ([]Object, error) {
+		ret, err := callLuaHandler(L, fn, o)
+		if err != nil {
+			return nil, err
+		}
+		switch v := ret.(type) {
+		case nil:
+			return []Object{}, nil
+		case Object:
+			// an empty table is an empty list.
+			if len(v) == 0 {
+				return []Object{}, nil
+			}
+		case []interface{}:
+			items := make([]Object, 0, len(v))
+			for _, item := range v {
+				o, ok := item.(Object)
+				if !ok {
+					return nil, fmt.Errorf("expected list of tables. got: %+v", item)
+				}
+				items = append(items, o)
+			}
+			return items, nil
+		}
+		return nil, fmt.Errorf("expected list of tables. got: %+v", ret)
+	}
+}
+
+// callLuaHandler calls fn with the object and returns its first value converted to go.
+// A second value which is not nil is the error.
+func callLuaHandler(L *lua.LState, fn *lua.LFunction, o Object) (interface{}, error) {
+	if err := L.CallByParam(lua.P{Fn: fn, NRet: 2, Protect: true}, toLTable(o)); err != nil {
+		return nil, err
+	}
+	ret, errValue := L.Get(-2), L.Get(-1)
+	L.Pop(2)
+	if errValue != lua.LNil {
+		return nil, errors.New(errValue.String())
+	}
+	return toGoValue(ret), nil
+}
+
+// tag adds or overwrites tags of a resource.
+// Input: id of the resource (or ARN of an iam user or role) and the tags {k = v}.
+func (l *LuaInterpreter) tag(L *lua.LState) int {
+	respTable := L.NewTable()
+
+	id, err := getData[string](L, 1)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	tags, err := getData[Object](L, 2)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if err := l.awsProvider.Tag(context.TODO(), id, toStringMap(tags)); err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(respTable)
+	return 1
+}
+
+// untag removes tags of a resource.
+// Input: id of the resource (or ARN of an iam user or role) and the list of keys. A map {k = v} can be given instead of the keys.
+func (l *LuaInterpreter) untag(L *lua.LState) int {
+	respTable := L.NewTable()
+
+	id, err := getData[string](L, 1)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	keys := []string{}
+	switch v := toGoValue(L.Get(2)).(type) {
+	case []interface{}:
+		for _, k := range v {
+			keys = append(keys, fmt.Sprint(k))
+		}
+	case Object:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	default:
+		L.Push(respTable)
+		L.Push(lua.LString(fmt.Sprintf("expected list of keys. got: %+v", L.Get(2))))
+		return 2
+	}
+
+	if err := l.awsProvider.Untag(context.TODO(), id, keys); err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(respTable)
+	return 1
+}
+
+// findByTag returns the list of resources {id, type, tags} having all the tags.
+// Input: the tags {k = v} and the options {types = {"aws_vpc", ...}}
+func (l *LuaInterpreter) findByTag(L *lua.LState) int {
+	respTable := L.NewTable()
+
+	tags, err := getData[Object](L, 1)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	opts, err := getData[Object](L, 2)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	resourceTypes := []string{}
+	for _, t := range opts.GetList("types") {
+		resourceTypes = append(resourceTypes, fmt.Sprint(t))
+	}
+
+	items, err := l.awsProvider.FindByTag(context.TODO(), toStringMap(tags), resourceTypes)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	respTable = toLList(items)
+	L.Push(respTable)
+	return 1
+}
+
+// latestImage returns the most recent non deprecated image matching the owner and the name pattern.
+// Input: {owner = "amazon", name = "al2023-ami-*-x86_64", filters = {...}}
+func (l *LuaInterpreter) latestImage(L *lua.LState) int {
+	respTable := L.NewTable()
+
+	obj, err := getData[Object](L, 1)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	filters := obj.GetList("filters")
+	if name := obj.GetString("name"); name != "" {
+		filters = append(filters, Object{"Name": "name", "Values": []interface{}{name}})
+	}
+	input := Object{
+		"filters": filters,
+		"latest":  true,
+	}
+	if owner := obj.GetString("owner"); owner != "" {
+		input["owners"] = []interface{}{owner}
+	}
+
+	images, err := l.awsProvider.List(context.TODO(), "aws_image", input)
+	if err != nil {
+		L.Push(respTable)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if len(images) == 0 {
+		L.Push(respTable)
+		L.Push(lua.LString("no image found"))
+		return 2
+	}
+
+	respTable = toLTable(images[0])
+	L.Push(respTable)
+	return 1
+}
+
 func getData[T any](L *lua.LState, idx int) (T, error) {
 	var t T
 	value := L.Get(idx)
@@ -139,8 +564,10 @@ func (l *LuaInterpreter) execute(name string, resource string, o Object) (Object
 	switch name {
 	case "create":
 		return l.awsProvider.Create(context.TODO(), resource, o)
-	case "list":
-		return l.awsProvider.List(context.TODO(), resource, o)
+	case "get":
+		return l.awsProvider.Get(context.TODO(), resource, o)
+	case "update":
+		return l.awsProvider.Update(context.TODO(), resource, o)
 	case "delete":
 		return l.awsProvider.Delete(context.TODO(), resource, o)
 	default:
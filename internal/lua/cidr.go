@@ -0,0 +1,249 @@
+package lua
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// maxHosts is the number of addresses returned by cidr.hosts without a limit.
+const maxHosts = 65536
+
+// CidrInterpreter is the cidr module, which plans IPv4 and IPv6 address blocks.
+// Like the other modules, invalid arguments return nil and an error.
+type CidrInterpreter struct{}
+
+func NewCidrModule() *CidrInterpreter {
+	return &CidrInterpreter{}
+}
+
+func (c *CidrInterpreter) Loader(L *lua.LState) int {
+	mod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"subnet":    c.subnet,
+		"host":      c.host,
+		"hosts":     c.hosts,
+		"contains":  c.contains,
+		"overlaps":  c.overlaps,
+		"next_free": c.nextFree,
+	})
+
+	L.Push(mod)
+	return 1
+}
+
+// subnet(base, newbits, netnum) returns the netnum-th block of base whose prefix is longer by newbits:
+// subnet("10.0.0.0/16", 8, 2) is 10.0.2.0/24.
+func (c *CidrInterpreter) subnet(L *lua.LState) int {
+	base, err := netip.ParsePrefix(L.CheckString(1))
+	if err != nil {
+		return pushError(L, err)
+	}
+	newbits, netnum := L.CheckInt(2), L.CheckInt64(3)
+
+	bits := base.Bits() + newbits
+	if newbits < 0 || bits > base.Addr().BitLen() {
+		return pushError(L, fmt.Errorf("cannot extend %s by %d bits", base, newbits))
+	}
+	if netnum < 0 || big.NewInt(netnum).Cmp(blockSize(newbits)) >= 0 {
+		return pushError(L, fmt.Errorf("%s has no block %d of /%d", base, netnum, bits))
+	}
+
+	start := new(big.Int).Mul(big.NewInt(netnum), blockSize(base.Addr().BitLen()-bits))
+	start.Add(start, addrToInt(base.Masked().Addr()))
+	L.Push(lua.LString(netip.PrefixFrom(intToAddr(start, base.Addr().Is4()), bits).String()))
+	return 1
+}
+
+// host(prefix, hostnum) returns the hostnum-th address of the block. A negative hostnum counts from the end:
+// host("10.0.1.0/24", -1) is 10.0.1.255.
+func (c *CidrInterpreter) host(L *lua.LState) int {
+	prefix, err := netip.ParsePrefix(L.CheckString(1))
+	if err != nil {
+		return pushError(L, err)
+	}
+	n := L.CheckInt64(2)
+	hostnum := big.NewInt(n)
+
+	size := blockSize(prefix.Addr().BitLen() - prefix.Bits())
+	if hostnum.Sign() < 0 {
+		hostnum.Add(hostnum, size)
+	}
+	if hostnum.Sign() < 0 || hostnum.Cmp(size) >= 0 {
+		return pushError(L, fmt.Errorf("%s has no host %d", prefix, n))
+	}
+
+	addr := hostnum.Add(hostnum, addrToInt(prefix.Masked().Addr()))
+	L.Push(lua.LString(intToAddr(addr, prefix.Addr().Is4()).String()))
+	return 1
+}
+
+// hosts(prefix, [limit]) returns the list of the addresses of the block, up to limit.
+// Blocks larger than 65536 addresses require a limit.
+func (c *CidrInterpreter) hosts(L *lua.LState) int {
+	prefix, err := netip.ParsePrefix(L.CheckString(1))
+	if err != nil {
+		return pushError(L, err)
+	}
+	limit := L.OptInt(2, 0)
+
+	size := blockSize(prefix.Addr().BitLen() - prefix.Bits())
+	if limit <= 0 {
+		if size.Cmp(big.NewInt(maxHosts)) > 0 {
+			return pushError(L, fmt.Errorf("%s has %s addresses, give a limit", prefix, size))
+		}
+		limit = int(size.Int64())
+	}
+
+	list := newList()
+	for addr := prefix.Masked().Addr(); addr.IsValid() && prefix.Contains(addr) && list.Len() < limit; addr = addr.Next() {
+		list.Append(lua.LString(addr.String()))
+	}
+	L.Push(list)
+	return 1
+}
+
+// contains(prefix, address or prefix) returns true when the address or the whole block is in the prefix.
+func (c *CidrInterpreter) contains(L *lua.LState) int {
+	prefix, err := netip.ParsePrefix(L.CheckString(1))
+	if err != nil {
+		return pushError(L, err)
+	}
+	other, err := parsePrefixOrAddr(L.CheckString(2))
+	if err != nil {
+		return pushError(L, err)
+	}
+
+	L.Push(lua.LBool(prefix.Bits() <= other.Bits() && prefix.Contains(other.Addr())))
+	return 1
+}
+
+// overlaps(a, b) returns true when the blocks have addresses in common.
+func (c *CidrInterpreter) overlaps(L *lua.LState) int {
+	a, err := netip.ParsePrefix(L.CheckString(1))
+	if err != nil {
+		return pushError(L, err)
+	}
+	b, err := netip.ParsePrefix(L.CheckString(2))
+	if err != nil {
+		return pushError(L, err)
+	}
+
+	L.Push(lua.LBool(a.Overlaps(b)))
+	return 1
+}
+
+// next_free(base, bits, used) returns the first /bits block of base which overlaps none of the used blocks,
+// or nil and an error when base is full.
+// used is a list of cidrs, or of tables like the subnets returned by aws.list("aws_subnet"), whose cidr_block,
+// ipv6_cidr_block and ipv6_cidr_block_association_set are used.
+func (c *CidrInterpreter) nextFree(L *lua.LState) int {
+	base, err := netip.ParsePrefix(L.CheckString(1))
+	if err != nil {
+		return pushError(L, err)
+	}
+	base = base.Masked()
+	bits := L.CheckInt(2)
+	if bits < base.Bits() || bits > base.Addr().BitLen() {
+		return pushError(L, fmt.Errorf("cannot allocate a /%d block in %s", bits, base))
+	}
+
+	used := []netip.Prefix{}
+	for _, s := range usedCidrs(toGoValue(L.OptTable(3, L.NewTable()))) {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return pushError(L, err)
+		}
+		if p.Overlaps(base) {
+			used = append(used, p.Masked())
+		}
+	}
+	sort.Slice(used, func(i, j int) bool {
+		return used[i].Addr().Less(used[j].Addr())
+	})
+
+	size := blockSize(base.Addr().BitLen() - bits)
+	end := new(big.Int).Add(addrToInt(base.Addr()), blockSize(base.Addr().BitLen()-base.Bits()))
+	candidate := addrToInt(base.Addr())
+	for _, u := range used {
+		block := netip.PrefixFrom(intToAddr(candidate, base.Addr().Is4()), bits)
+		if !block.Overlaps(u) {
+			if u.Addr().Less(block.Addr()) {
+				// u ends before the candidate
+				continue
+			}
+			break
+		}
+		// the next aligned block after the end of u
+		uEnd := new(big.Int).Add(addrToInt(u.Addr()), blockSize(u.Addr().BitLen()-u.Bits()))
+		uEnd.Add(uEnd, new(big.Int).Sub(size, big.NewInt(1)))
+		candidate = uEnd.Sub(uEnd, new(big.Int).Mod(uEnd, size))
+		if candidate.Cmp(end) >= 0 {
+			break
+		}
+	}
+	if new(big.Int).Add(candidate, size).Cmp(end) > 0 {
+		return pushError(L, fmt.Errorf("no free /%d block in %s", bits, base))
+	}
+
+	L.Push(lua.LString(netip.PrefixFrom(intToAddr(candidate, base.Addr().Is4()), bits).String()))
+	return 1
+}
+
+// usedCidrs returns the cidrs of the list given to next_free.
+func usedCidrs(v interface{}) []string {
+	cidrs := []string{}
+	items, ok := v.([]interface{})
+	if !ok {
+		return cidrs
+	}
+	for _, item := range items {
+		switch value := item.(type) {
+		case string:
+			cidrs = append(cidrs, value)
+		case Object:
+			for _, key := range []string{"cidr_block", "cidr", "ipv6_cidr_block"} {
+				if s := value.GetString(key); s != "" {
+					cidrs = append(cidrs, s)
+				}
+			}
+			cidrs = append(cidrs, usedCidrs(value["ipv6_cidr_block_association_set"])...)
+		}
+	}
+	return cidrs
+}
+
+func parsePrefixOrAddr(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	return netip.ParsePrefix(s)
+}
+
+// blockSize returns 2^bits.
+func blockSize(bits int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits))
+}
+
+func addrToInt(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+func intToAddr(i *big.Int, is4 bool) netip.Addr {
+	if is4 {
+		var b [4]byte
+		i.FillBytes(b[:])
+		return netip.AddrFrom4(b)
+	}
+	var b [16]byte
+	i.FillBytes(b[:])
+	return netip.AddrFrom16(b)
+}
+
+func pushError(L *lua.LState, err error) int {
+	L.Push(lua.LNil)
+	L.Push(lua.LString(err.Error()))
+	return 2
+}
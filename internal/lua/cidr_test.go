@@ -0,0 +1,72 @@
+package lua
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestCidr(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	L.PreloadModule("cidr", NewCidrModule().Loader)
+
+	script := `
+local cidr = require("cidr")
+
+assert(cidr.subnet("10.0.0.0/16", 8, 2) == "10.0.2.0/24")
+assert(cidr.subnet("10.0.0.0/16", 8, 255) == "10.0.255.0/24")
+assert(cidr.subnet("10.0.5.0/16", 4, 1) == "10.0.16.0/20")
+assert(cidr.subnet("2600:1f18:abcd:1200::/56", 8, 3) == "2600:1f18:abcd:1203::/64")
+local s, err = cidr.subnet("10.0.0.0/16", 8, 256)
+assert(s == nil and err == "10.0.0.0/16 has no block 256 of /24", err)
+local s, err = cidr.subnet("10.0.0.0/16", 17, 0)
+assert(s == nil and err == "cannot extend 10.0.0.0/16 by 17 bits", err)
+local s, err = cidr.subnet("10.0.0/16", 8, 0)
+assert(s == nil and err ~= nil)
+
+assert(cidr.host("10.0.1.0/24", 4) == "10.0.1.4")
+assert(cidr.host("10.0.1.0/24", -1) == "10.0.1.255")
+assert(cidr.host("2600:1f18::/64", 1) == "2600:1f18::1")
+local h, err = cidr.host("10.0.1.0/24", 256)
+assert(h == nil and err == "10.0.1.0/24 has no host 256", err)
+
+local hosts = cidr.hosts("10.0.1.0/30")
+assert(#hosts == 4 and hosts[1] == "10.0.1.0" and hosts[4] == "10.0.1.3")
+assert(#cidr.hosts("2600:1f18::/64", 3) == 3)
+local hosts, err = cidr.hosts("10.0.0.0/8")
+assert(hosts == nil and err:find("give a limit"), err)
+
+assert(cidr.contains("10.0.0.0/16", "10.0.3.4"))
+assert(cidr.contains("10.0.0.0/16", "10.0.3.0/24"))
+assert(not cidr.contains("10.0.0.0/16", "10.0.0.0/8"))
+assert(not cidr.contains("10.0.0.0/16", "2600:1f18::1"))
+assert(cidr.overlaps("10.0.0.0/16", "10.0.200.0/24"))
+assert(not cidr.overlaps("10.0.0.0/24", "10.0.1.0/24"))
+
+assert(cidr.next_free("10.0.0.0/16", 24, {}) == "10.0.0.0/24")
+assert(cidr.next_free("10.0.0.0/16", 24, { "10.0.0.0/24", "10.0.1.0/25", "192.168.0.0/24" }) == "10.0.2.0/24")
+assert(cidr.next_free("10.0.0.0/16", 22, { "10.0.0.0/24", "10.0.5.0/24" }) == "10.0.8.0/22")
+assert(cidr.next_free("10.0.0.0/16", 24, { "10.0.1.0/24", "10.0.0.0/20" }) == "10.0.16.0/24")
+local subnets = {
+    { id = "subnet-1", cidr_block = "10.0.0.0/24", ipv6_cidr_block_association_set = { { ipv6_cidr_block = "2600:1f18::/64" } } },
+    { id = "subnet-2", cidr_block = "10.0.1.0/24" },
+}
+assert(cidr.next_free("10.0.0.0/16", 24, subnets) == "10.0.2.0/24")
+assert(cidr.next_free("2600:1f18::/56", 64, subnets) == "2600:1f18:0:1::/64")
+
+local used = {}
+for i = 1, 4 do
+    table.insert(used, cidr.next_free("10.0.0.0/22", 24, used))
+end
+assert(used[4] == "10.0.3.0/24")
+local block, err = cidr.next_free("10.0.0.0/22", 24, used)
+assert(block == nil and err == "no free /24 block in 10.0.0.0/22", err)
+local block, err = cidr.next_free("10.0.0.0/16", 8, {})
+assert(block == nil and err == "cannot allocate a /8 block in 10.0.0.0/16", err)
+`
+	Expect(L.DoString(script)).To(Succeed())
+}
@@ -0,0 +1,128 @@
+package lua
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"gopkg.in/yaml.v3"
+)
+
+// EncodingInterpreter is the json or the yaml module.
+//
+// Values are converted like the values given to the aws module: tables whose keys are 1..n are arrays
+// and other tables are objects, encoded with sorted keys. An empty table is an object unless it is marked
+// with array(t). Decoded arrays are marked, so they are encoded again as arrays even when emptied.
+// null decodes to the value null, which can be stored in a table unlike nil, and encodes to null.
+type EncodingInterpreter struct {
+	format string
+}
+
+func NewJsonModule() *EncodingInterpreter {
+	return &EncodingInterpreter{format: "json"}
+}
+
+func NewYamlModule() *EncodingInterpreter {
+	return &EncodingInterpreter{format: "yaml"}
+}
+
+func (e *EncodingInterpreter) Loader(L *lua.LState) int {
+	mod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"encode": e.encode,
+		"decode": e.decode,
+		"array":  e.array,
+	})
+	mod.RawSetString("null", null)
+
+	L.Push(mod)
+	return 1
+}
+
+// encode(value, [{indent = string}]) returns the encoded value or nil and an error.
+// The indent option is only used by json; yaml is indented by 2 spaces.
+func (e *EncodingInterpreter) encode(L *lua.LState) int {
+	value := toGoValue(L.CheckAny(1))
+	opts := L.OptTable(2, L.NewTable())
+	if !isData(value) {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("%s: cannot encode functions or userdata", e.format)))
+		return 2
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch e.format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", lua.LVAsString(opts.RawGetString("indent")))
+		err = enc.Encode(value)
+	case "yaml":
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err = enc.Encode(value); err == nil {
+			err = enc.Close()
+		}
+	}
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("%s: %s", e.format, err)))
+		return 2
+	}
+
+	out := buf.String()
+	if e.format == "json" {
+		out = strings.TrimSuffix(out, "\n")
+	}
+	L.Push(lua.LString(out))
+	return 1
+}
+
+// decode(string) returns the decoded value or nil and an error.
+func (e *EncodingInterpreter) decode(L *lua.LState) int {
+	data := []byte(L.CheckString(1))
+
+	var value interface{}
+	var err error
+	switch e.format {
+	case "json":
+		err = json.Unmarshal(data, &value)
+	case "yaml":
+		err = yaml.Unmarshal(data, &value)
+	}
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("%s: %s", e.format, err)))
+		return 2
+	}
+
+	L.Push(toLValue(withNull(toObjectValue(value))))
+	return 1
+}
+
+// array([table]) marks the table as an array, so that it is encoded as [] when empty. It returns the table.
+func (e *EncodingInterpreter) array(L *lua.LState) int {
+	t := L.OptTable(1, L.NewTable())
+	markArray(t)
+	L.Push(t)
+	return 1
+}
+
+// withNull replaces the nil values of the decoded value by null.
+func withNull(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil:
+		return null
+	case Object:
+		for k, item := range value {
+			value[k] = withNull(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = withNull(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,92 @@
+package lua
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestJson(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	L.PreloadModule("json", NewJsonModule().Loader)
+
+	script := `
+local json = require("json")
+
+local policy = {
+    Version = "2012-10-17",
+    Statement = {
+        { Effect = "Allow", Action = { "ec2:Describe*" }, Resource = "*" },
+    },
+}
+assert(json.encode(policy) == '{"Statement":[{"Action":["ec2:Describe*"],"Effect":"Allow","Resource":"*"}],"Version":"2012-10-17"}', json.encode(policy))
+assert(json.encode({ b = 1, a = 2.5 }, { indent = "  " }) == '{\n  "a": 2.5,\n  "b": 1\n}')
+
+assert(json.encode({}) == "{}")
+assert(json.encode(json.array()) == "[]")
+assert(json.encode({ ids = json.array({}) }) == '{"ids":[]}')
+assert(json.encode({ a = json.null }) == '{"a":null}')
+assert(json.encode({ "a", json.null, "c" }) == '["a",null,"c"]')
+
+local doc = json.decode('{"ids": [], "tags": {}, "name": null, "list": [1, null, 3]}')
+assert(doc.name == json.null)
+assert(doc.list[2] == json.null and #doc.list == 3)
+assert(json.encode(doc) == '{"ids":[],"list":[1,null,3],"name":null,"tags":{}}', json.encode(doc))
+
+local list = json.decode('[1]')
+table.remove(list)
+assert(json.encode(list) == "[]")
+
+-- the metatables of the lists and of null are not shared with the other values.
+getmetatable(list).__index = function() return "changed" end
+assert(json.decode("[]")[1] == nil)
+assert(getmetatable(json.null) == false)
+assert(not pcall(setmetatable, json.null, {}))
+
+local value, err = json.decode("{")
+assert(value == nil and err:find("^json: "), err)
+local value, err = json.encode({ f = print })
+assert(value == nil and err == "json: cannot encode functions or userdata", err)
+`
+	Expect(L.DoString(script)).To(Succeed())
+}
+
+func TestYaml(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	L.PreloadModule("json", NewJsonModule().Loader)
+	L.PreloadModule("yaml", NewYamlModule().Loader)
+
+	script := `
+local json = require("json")
+local yaml = require("yaml")
+assert(json.null == yaml.null)
+
+local config = yaml.decode([[
+vpc:
+  cidr: 10.0.0.0/16
+  az_count: 3
+  subnets: []
+  owner: ~
+  created: 2024-01-01
+]])
+assert(config.vpc.cidr == "10.0.0.0/16")
+assert(config.vpc.az_count == 3)
+assert(config.vpc.owner == yaml.null)
+assert(config.vpc.created == "2024-01-01")
+
+local out = yaml.encode(config)
+assert(out == "vpc:\n  az_count: 3\n  cidr: 10.0.0.0/16\n  created: \"2024-01-01\"\n  owner: null\n  subnets: []\n", out)
+assert(json.encode(yaml.decode(out)) == json.encode(config))
+
+local value, err = yaml.decode("a: [")
+assert(value == nil and err:find("^yaml: "), err)
+`
+	Expect(L.DoString(script)).To(Succeed())
+}
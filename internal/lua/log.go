@@ -0,0 +1,54 @@
+package lua
+
+import (
+	"github.com/tupyy/aws-lua/internal/logger"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// LogInterpreter is the log module, which writes with the logger of the run:
+//
+//	log.info("vpc created", { id = vpc.id, cidr = vpc.cidr_block })
+//
+// The fields are redacted like the fields of the providers.
+type LogInterpreter struct {
+	logger *logger.Logger
+}
+
+func NewLogModule(l *logger.Logger) *LogInterpreter {
+	return &LogInterpreter{logger: l}
+}
+
+func (m *LogInterpreter) Loader(L *lua.LState) int {
+	mod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"debug": m.logFunc(logger.LevelDebug),
+		"info":  m.logFunc(logger.LevelInfo),
+		"warn":  m.logFunc(logger.LevelWarn),
+		"error": m.logFunc(logger.LevelError),
+	})
+
+	L.Push(mod)
+	return 1
+}
+
+// logFunc returns the function log.<level>(msg, [fields]). The fields are written sorted by key.
+func (m *LogInterpreter) logFunc(level logger.Level) lua.LGFunction {
+	return func(L *lua.LState) int {
+		msg := L.CheckAny(1).String()
+		if !m.logger.Enabled(level) {
+			return 0
+		}
+
+		fields := []interface{}{}
+		if t := L.OptTable(2, nil); t != nil {
+			o, ok := toGoValue(t).(Object)
+			if !ok {
+				L.ArgError(2, "expected a table of fields")
+			}
+			for _, key := range sortedKeys(o) {
+				fields = append(fields, key, o[key])
+			}
+		}
+		m.logger.Log(level, msg, fields...)
+		return 0
+	}
+}
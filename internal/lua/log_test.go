@@ -0,0 +1,34 @@
+package lua
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/logger"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestLog(t *testing.T) {
+	RegisterTestingT(t)
+
+	var out bytes.Buffer
+	l, err := logger.New(&out, logger.LevelInfo, "text")
+	Expect(err).To(BeNil())
+
+	L := lua.NewState()
+	defer L.Close()
+	L.PreloadModule("log", NewLogModule(l).Loader)
+
+	script := `
+local log = require("log")
+log.debug("not written")
+log.info("key created", { user = "bob", secret_access_key = "s3cr3t", tags = { env = "dev" } })
+log.error("failed")
+local ok, err = pcall(log.warn, "bad fields", { "a", "b" })
+assert(not ok and err:find("expected a table of fields"), err)
+`
+	Expect(L.DoString(script)).To(Succeed())
+	Expect(out.String()).To(MatchRegexp(`level=info msg="key created" secret_access_key=REDACTED tags="{\\"env\\":\\"dev\\"}" user=bob\n.*level=error msg=failed\n$`))
+	Expect(out.String()).ToNot(ContainSubstring("not written"))
+}
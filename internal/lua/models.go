@@ -6,14 +6,6 @@ import (
 )
 
 const (
-	AwsUser                 string = "aws_user"
-	AwsAccessKey            string = "aws_access_key"
-	AwsVpc                  string = "aws_vpc"
-	AwsSubnet               string = "aws_subnet"
-	AwsRoute                string = "aws_route"
-	AwsInternetGateway      string = "aws_igw"
-	AwsNatGateway           string = "aws_nat"
-	AwsAZs                  string = "aws_availability_zones"
 	UnknownType             string = "unknown_type"
 	AwsProviderType         string = "aws"
 	UnsupportedProviderType string = "unsupported_type"
@@ -53,6 +45,14 @@ func (o Object) GetBool(key string) bool {
 	return v
 }
 
+func (o Object) GetNumber(key string) float64 {
+	v, err := getKey[float64](o, key)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
 func (o Object) GetObject(key string) map[string]interface{} {
 	v, err := getKey[Object](o, key)
 	if err != nil {
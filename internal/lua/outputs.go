@@ -0,0 +1,85 @@
+package lua
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// masked replaces the sensitive outputs when they are not shown.
+const masked = "<sensitive>"
+
+type output struct {
+	value     interface{}
+	sensitive bool
+}
+
+// Outputs are the named values set by the script with aws.output and written at the end of the run.
+type Outputs struct {
+	values map[string]output
+}
+
+func NewOutputs() *Outputs {
+	return &Outputs{values: make(map[string]output)}
+}
+
+// Set sets an output. An output set twice keeps its last value.
+func (o *Outputs) Set(name string, value interface{}, sensitive bool) {
+	o.values[name] = output{value: value, sensitive: sensitive}
+}
+
+func (o *Outputs) Len() int {
+	return len(o.values)
+}
+
+// Write writes the outputs as a json or yaml object sorted by name. Sensitive values are masked unless showSensitive is set.
+func (o *Outputs) Write(w io.Writer, format string, showSensitive bool) error {
+	values := make(map[string]interface{}, len(o.values))
+	for name, out := range o.values {
+		values[name] = out.value
+		if out.sensitive && !showSensitive {
+			values[name] = masked
+		}
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(values)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(values); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("unknown output format %q, expected json or yaml", format)
+}
+
+// isData returns true when the value converted from lua holds no function or userdata.
+func isData(v interface{}) bool {
+	switch value := v.(type) {
+	case nil, bool, string, float64:
+		return true
+	case Object:
+		for _, item := range value {
+			if !isData(item) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		for _, item := range value {
+			if !isData(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package lua
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestOutputs(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	module := NewAwsModule(nil)
+	L.PreloadModule("aws", module.Loader)
+
+	script := `
+local aws = require("aws")
+aws.output("vpc_id", "vpc-1")
+aws.output("subnets", { "subnet-1", "subnet-2" })
+aws.output("key", { id = "AKIA1", secret = "s3cr3t" }, { sensitive = true })
+aws.output("vpc_id", "vpc-2")
+
+local ok, err = pcall(aws.output, "fn", function() end)
+assert(not ok and err:find("outputs can only hold"), err)
+local ok, err = pcall(aws.output, "", "value")
+assert(not ok and err:find("output name is empty"), err)
+`
+	Expect(L.DoString(script)).To(Succeed())
+	Expect(module.Outputs().Len()).To(Equal(3))
+
+	var out bytes.Buffer
+	Expect(module.Outputs().Write(&out, "json", false)).To(Succeed())
+	Expect(out.String()).To(Equal(`{
+  "key": "<sensitive>",
+  "subnets": [
+    "subnet-1",
+    "subnet-2"
+  ],
+  "vpc_id": "vpc-2"
+}
+`))
+
+	out.Reset()
+	Expect(module.Outputs().Write(&out, "yaml", true)).To(Succeed())
+	Expect(out.String()).To(Equal(`key:
+  id: AKIA1
+  secret: s3cr3t
+subnets:
+  - subnet-1
+  - subnet-2
+vpc_id: vpc-2
+`))
+
+	Expect(module.Outputs().Write(&out, "toml", false)).ToNot(Succeed())
+}
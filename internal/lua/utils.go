@@ -2,15 +2,57 @@ package lua
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 
 	lua "github.com/yuin/gopher-lua"
 )
 
+// arrayField marks the metatable of the lists built from Go values or by json.array,
+// so that an empty list is converted back to a list and not to an Object.
+// Every list has its own metatable: a script changing it does not change the other lists.
+const arrayField = "__array"
+
+// null is the value of json.null and yaml.null, converted to nil. Unlike nil, it can be stored in a table.
+// It is shared by all the states, so its metatable is protected from getmetatable and setmetatable.
+var null = &lua.LUserData{Value: nil, Metatable: protectedMetatable()}
+
+func protectedMetatable() *lua.LTable {
+	mt := &lua.LTable{Metatable: lua.LNil}
+	mt.RawSetString("__metatable", lua.LFalse)
+	return mt
+}
+
+func newList() *lua.LTable {
+	t := &lua.LTable{}
+	markArray(t)
+	return t
+}
+
+// markArray replaces the metatable of the table with a new one marking it as a list.
+func markArray(t *lua.LTable) {
+	mt := &lua.LTable{Metatable: lua.LNil}
+	mt.RawSetString(arrayField, lua.LTrue)
+	t.Metatable = mt
+}
+
+func isArray(t *lua.LTable) bool {
+	mt, ok := t.Metatable.(*lua.LTable)
+	return ok && mt.RawGetString(arrayField) == lua.LTrue
+}
+
 // toGoValue converts the given LValue to a Go object.
+// Tables whose keys are exactly 1..n are converted to lists, as are empty tables marked as lists. Every other table,
+// including mixed tables with both an array part and a hash part, is converted to an Object with string keys.
 func toGoValue(lv lua.LValue) interface{} {
 	switch v := lv.(type) {
 	case *lua.LNilType:
 		return nil
+	case *lua.LUserData:
+		if v == null {
+			return nil
+		}
+		return v
 	case lua.LBool:
 		return bool(v)
 	case lua.LString:
@@ -19,56 +61,134 @@ func toGoValue(lv lua.LValue) interface{} {
 		return float64(v)
 	case *lua.LTable:
 		maxn := v.MaxN()
-		if maxn == 0 { // table
-			ret := Object{}
-			v.ForEach(func(key, value lua.LValue) {
-				keystr := fmt.Sprint(toGoValue(key))
-				ret[keystr] = toGoValue(value)
-			})
-			return ret
-		} else { // array
+		if (maxn > 0 || isArray(v)) && countKeys(v) == maxn { // array
 			ret := make([]interface{}, 0, maxn)
 			for i := 1; i <= maxn; i++ {
 				ret = append(ret, toGoValue(v.RawGetInt(i)))
 			}
 			return ret
 		}
+		// table
+		ret := Object{}
+		v.ForEach(func(key, value lua.LValue) {
+			keystr := fmt.Sprint(toGoValue(key))
+			ret[keystr] = toGoValue(value)
+		})
+		return ret
 	default:
 		return v
 	}
 }
 
+// sortedKeys returns the keys of the object in order.
+func sortedKeys(o Object) []string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func countKeys(t *lua.LTable) int {
+	n := 0
+	t.ForEach(func(_, _ lua.LValue) {
+		n++
+	})
+	return n
+}
+
+// toLTable converts the object into a lua table.
 func toLTable(o Object) *lua.LTable {
 	t := &lua.LTable{}
 	for k, v := range o {
-		switch val := v.(type) {
-		case bool:
-			t.RawSetH(lua.LString(k), lua.LBool(val))
-		case int:
-			t.RawSetString(k, lua.LNumber(val))
-		case string:
-			t.RawSetString(k, lua.LString(val))
-		case []string:
-			list := &lua.LTable{}
-			for _, v := range val {
-				list.Append(lua.LString(v))
-			}
-			t.RawSetString(k, list)
-		case []interface{}:
-			list := &lua.LTable{}
-			for _, v := range val {
-				list.Append(toLTable(v.(Object)))
-			}
-			if list.Len() > 0 {
-				t.RawSetString(k, list)
-			}
-		case map[string]interface{}:
-			tbl := toLTable(val)
-			t.RawSetString(k, tbl)
-		case Object:
-			tbl := toLTable(val)
-			t.RawSetString(k, tbl)
-		}
+		t.RawSetString(k, toLValue(v))
+	}
+	return t
+}
+
+// toStringMap converts the values of the object into strings.
+func toStringMap(o Object) map[string]string {
+	m := make(map[string]string, len(o))
+	for k, v := range o {
+		m[k] = fmt.Sprint(v)
+	}
+	return m
+}
+
+// toLList converts the objects into a lua list.
+func toLList(items []Object) *lua.LTable {
+	t := newList()
+	for _, o := range items {
+		t.Append(toLTable(o))
 	}
 	return t
 }
+
+// toLValue converts a Go value into a LValue.
+// Numbers of any kind are converted to LNumber, pointers are dereferenced, slices and arrays become lists
+// (empty ones included, marked as lists) and maps become tables. Values which cannot be represented are converted to LNil.
+func toLValue(v interface{}) lua.LValue {
+	switch val := v.(type) {
+	case nil:
+		return lua.LNil
+	case lua.LValue:
+		return val
+	case bool:
+		return lua.LBool(val)
+	case string:
+		return lua.LString(val)
+	case int:
+		return lua.LNumber(val)
+	case int32:
+		return lua.LNumber(val)
+	case int64:
+		return lua.LNumber(val)
+	case float64:
+		return lua.LNumber(val)
+	case Object:
+		return toLTable(val)
+	case map[string]interface{}:
+		return toLTable(val)
+	case []interface{}:
+		list := newList()
+		for _, item := range val {
+			list.Append(toLValue(item))
+		}
+		return list
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return lua.LNil
+		}
+		return toLValue(rv.Elem().Interface())
+	case reflect.String:
+		return lua.LString(rv.String())
+	case reflect.Bool:
+		return lua.LBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return lua.LNumber(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return lua.LNumber(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return lua.LNumber(rv.Float())
+	case reflect.Slice, reflect.Array:
+		list := newList()
+		for i := 0; i < rv.Len(); i++ {
+			list.Append(toLValue(rv.Index(i).Interface()))
+		}
+		return list
+	case reflect.Map:
+		t := &lua.LTable{}
+		iter := rv.MapRange()
+		for iter.Next() {
+			t.RawSetString(fmt.Sprint(iter.Key().Interface()), toLValue(iter.Value().Interface()))
+		}
+		return t
+	}
+
+	return lua.LNil
+}
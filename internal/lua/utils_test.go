@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	. "github.com/onsi/gomega"
+	lua "github.com/yuin/gopher-lua"
 )
 
 func TestToLTable(t *testing.T) {
@@ -21,9 +22,101 @@ func TestToLTable(t *testing.T) {
 	fmt.Printf("%+v\n", *res)
 }
 
-// func TestFromLTable(t *testing.T) {
-// 	RegisterTestingT(t)
+func TestToLTableValues(t *testing.T) {
+	RegisterTestingT(t)
+
+	name := "user"
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected lua.LValue
+	}{
+		{name: "float64", value: 62.5, expected: lua.LNumber(62.5)},
+		{name: "int64", value: int64(251), expected: lua.LNumber(251)},
+		{name: "string pointer", value: &name, expected: lua.LString("user")},
+		{name: "nil string pointer", value: (*string)(nil), expected: lua.LNil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := toLTable(Object{"key": tt.value})
+			Expect(res.RawGetString("key")).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestToLTableLists(t *testing.T) {
+	RegisterTestingT(t)
+
+	res := toLTable(Object{
+		"strings": []interface{}{"rtb-1", "rtb-2"},
+		"objects": []interface{}{Object{"Key": "k"}},
+		"typed":   []string{"a"},
+		"empty":   []interface{}{},
+	})
+
+	strings, ok := res.RawGetString("strings").(*lua.LTable)
+	Expect(ok).To(BeTrue())
+	Expect(strings.Len()).To(Equal(2))
+	Expect(strings.RawGetInt(1)).To(Equal(lua.LString("rtb-1")))
+
+	objects, ok := res.RawGetString("objects").(*lua.LTable)
+	Expect(ok).To(BeTrue())
+	Expect(objects.RawGetInt(1).(*lua.LTable).RawGetString("Key")).To(Equal(lua.LString("k")))
+
+	typed, ok := res.RawGetString("typed").(*lua.LTable)
+	Expect(ok).To(BeTrue())
+	Expect(typed.Len()).To(Equal(1))
+
+	empty, ok := res.RawGetString("empty").(*lua.LTable)
+	Expect(ok).To(BeTrue())
+	Expect(empty.Len()).To(Equal(0))
+}
+
+func TestToGoValue(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := lua.NewState()
+	defer L.Close()
 
-// 	lv := &lua.LTable{
-// 		"tag"
-// }
+	tests := []struct {
+		name     string
+		script   string
+		expected interface{}
+	}{
+		{
+			name:     "array",
+			script:   `return {"a", "b"}`,
+			expected: []interface{}{"a", "b"},
+		},
+		{
+			name:     "table",
+			script:   `return {cidr = "10.0.0.0/16"}`,
+			expected: Object{"cidr": "10.0.0.0/16"},
+		},
+		{
+			name:     "empty table",
+			script:   `return {}`,
+			expected: Object{},
+		},
+		{
+			name:     "mixed table",
+			script:   `return {"a", "b", name = "c"}`,
+			expected: Object{"1": "a", "2": "b", "name": "c"},
+		},
+		{
+			name:     "array with holes",
+			script:   `return {"a", nil, "c"}`,
+			expected: Object{"1": "a", "3": "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Expect(L.DoString(tt.script)).To(Succeed())
+			v := L.Get(-1)
+			L.Pop(1)
+			Expect(toGoValue(v)).To(Equal(tt.expected))
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package lua
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"gopkg.in/yaml.v3"
+)
+
+// Vars are the input variables of a script, exposed as the read-only global table "vars".
+//
+// The script declares its variables by calling the table. Declared variables have a default value,
+// are required or have a type to which the values given as strings by --var are converted:
+//
+//	vars({
+//	    env = { required = true },
+//	    cidr = { default = "10.0.0.0/16" },
+//	    az_count = { type = "number", default = 2 },
+//	    owner = "infra", -- same as { default = "infra" }
+//	})
+//
+// Calling the table without argument returns a copy of the variables which can be iterated.
+type Vars struct {
+	values Object
+}
+
+func NewVars(values Object) *Vars {
+	if values == nil {
+		values = Object{}
+	}
+	return &Vars{values: values}
+}
+
+// ParseVar parses a variable given as key=value.
+func ParseVar(s string) (string, string, error) {
+	key, value, found := strings.Cut(s, "=")
+	if !found || strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("invalid variable %q, expected key=value", s)
+	}
+	return strings.TrimSpace(key), value, nil
+}
+
+// LoadVarFile reads the variables of a json or yaml file, according to its extension.
+func LoadVarFile(path string) (Object, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values interface{}
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("%s: unsupported variable file, expected .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	o, ok := toObjectValue(values).(Object)
+	if !ok {
+		return nil, fmt.Errorf("%s: the variables must be an object", path)
+	}
+	return o, nil
+}
+
+// Register sets the global "vars" of the state.
+func (v *Vars) Register(L *lua.LState) {
+	values := toLTable(v.values)
+	proxy := readOnly(L, values)
+	proxy.Metatable.(*lua.LTable).RawSetString("__call", L.NewFunction(func(L *lua.LState) int {
+		if decls := L.OptTable(2, nil); decls != nil {
+			if err := declare(values, toGoValue(decls)); err != nil {
+				L.RaiseError("%s", err)
+			}
+		}
+		L.Push(copyTable(values))
+		return 1
+	}))
+	L.SetGlobal("vars", proxy)
+}
+
+// declare applies the declarations to the values: defaults are set, types are converted and required variables checked.
+func declare(values *lua.LTable, decls interface{}) error {
+	o, ok := decls.(Object)
+	if !ok {
+		return fmt.Errorf("vars: expected a table of declarations")
+	}
+
+	missing := []string{}
+	for _, name := range sortedKeys(o) {
+		decl, ok := o[name].(Object)
+		if !ok {
+			decl = Object{"default": o[name]}
+		}
+
+		value := values.RawGetString(name)
+		if value == lua.LNil {
+			if decl.GetBool("required") {
+				missing = append(missing, name)
+				continue
+			}
+			if d, found := decl["default"]; found {
+				values.RawSetString(name, toLValue(d))
+			}
+			continue
+		}
+
+		converted, err := convertVar(name, value, decl.GetString("type"))
+		if err != nil {
+			return err
+		}
+		values.RawSetString(name, converted)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required variables: %s (set them with --var or --var-file)", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// convertVar converts the value of a variable to the declared type. Values given by --var are strings.
+func convertVar(name string, value lua.LValue, kind string) (lua.LValue, error) {
+	s, isString := value.(lua.LString)
+	switch kind {
+	case "":
+		return value, nil
+	case "string":
+		if value.Type() == lua.LTTable {
+			break
+		}
+		return lua.LString(value.String()), nil
+	case "number":
+		if value.Type() == lua.LTNumber {
+			return value, nil
+		}
+		if isString {
+			if n, err := strconv.ParseFloat(string(s), 64); err == nil {
+				return lua.LNumber(n), nil
+			}
+		}
+	case "boolean":
+		if value.Type() == lua.LTBool {
+			return value, nil
+		}
+		if isString {
+			if b, err := strconv.ParseBool(string(s)); err == nil {
+				return lua.LBool(b), nil
+			}
+		}
+	case "table":
+		if value.Type() == lua.LTTable {
+			return value, nil
+		}
+	default:
+		return nil, fmt.Errorf("variable %q: unknown type %q", name, kind)
+	}
+	return nil, fmt.Errorf("variable %q: %s is not a %s", name, value.String(), kind)
+}
+
+// readOnly returns a proxy of the table which raises an error on assignment. Nested tables are proxied too.
+func readOnly(L *lua.LState, t *lua.LTable) *lua.LTable {
+	proxy := L.NewTable()
+	mt := L.NewTable()
+	mt.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+		v := t.RawGet(L.Get(2))
+		if nested, ok := v.(*lua.LTable); ok {
+			v = readOnly(L, nested)
+		}
+		L.Push(v)
+		return 1
+	}))
+	mt.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		L.RaiseError("vars are read-only")
+		return 0
+	}))
+	// the metatable cannot be read or replaced to write into the variables.
+	mt.RawSetString("__metatable", lua.LFalse)
+	L.SetMetatable(proxy, mt)
+	return proxy
+}
+
+func copyTable(t *lua.LTable) *lua.LTable {
+	c := &lua.LTable{}
+	t.ForEach(func(k, v lua.LValue) {
+		if nested, ok := v.(*lua.LTable); ok {
+			v = copyTable(nested)
+		}
+		c.RawSet(k, v)
+	})
+	return c
+}
+
+// toObjectValue converts the values decoded from json or yaml into the values coming from lua.
+func toObjectValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		o := make(Object, len(value))
+		for k, item := range value {
+			o[k] = toObjectValue(item)
+		}
+		return o
+	case map[interface{}]interface{}:
+		o := make(Object, len(value))
+		for k, item := range value {
+			o[fmt.Sprint(k)] = toObjectValue(item)
+		}
+		return o
+	case []interface{}:
+		list := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			list = append(list, toObjectValue(item))
+		}
+		return list
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case uint64:
+		return float64(value)
+	case time.Time: // unquoted yaml timestamps
+		if value.Equal(value.Truncate(24*time.Hour)) && value.Location() == time.UTC {
+			return value.Format("2006-01-02")
+		}
+		return value.Format(time.RFC3339Nano)
+	}
+	return v
+}
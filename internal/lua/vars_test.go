@@ -0,0 +1,88 @@
+package lua
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestVars(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	NewVars(Object{
+		"env":      "prod",
+		"az_count": "3",
+		"network":  Object{"cidr": "10.1.0.0/16"},
+	}).Register(L)
+
+	script := `
+vars({
+    env = { required = true },
+    az_count = { type = "number", default = 2 },
+    owner = "infra",
+    debug = { type = "boolean", default = false },
+})
+assert(vars.env == "prod")
+assert(vars.az_count == 3)
+assert(vars.owner == "infra")
+assert(vars.debug == false)
+assert(vars.network.cidr == "10.1.0.0/16")
+assert(vars.unknown == nil)
+
+local ok, err = pcall(function() vars.env = "dev" end)
+assert(not ok and err:find("vars are read%-only"), err)
+local ok, err = pcall(function() vars.network.cidr = "10.2.0.0/16" end)
+assert(not ok, "nested tables are read-only")
+assert(getmetatable(vars) == false and getmetatable(vars.network) == false)
+local ok = pcall(setmetatable, vars.network, {})
+assert(not ok, "the metatable of the variables is protected")
+
+local all = vars()
+all.env = "dev"
+assert(vars.env == "prod")
+
+local ok, err = pcall(vars, { region = { required = true }, zone = { required = true } })
+assert(not ok and err:find("missing required variables: region, zone"), err)
+
+local ok, err = pcall(vars, { env = { type = "number" } })
+assert(not ok and err:find('variable "env": prod is not a number'), err)
+`
+	Expect(L.DoString(script)).To(Succeed())
+}
+
+func TestLoadVarFile(t *testing.T) {
+	RegisterTestingT(t)
+	dir := t.TempDir()
+
+	yamlFile := filepath.Join(dir, "prod.yaml")
+	Expect(os.WriteFile(yamlFile, []byte("env: prod\naz_count: 3\nsubnets:\n  - 10.0.1.0/24\n  - 10.0.2.0/24\n"), 0644)).To(Succeed())
+	o, err := LoadVarFile(yamlFile)
+	Expect(err).To(BeNil())
+	Expect(o).To(Equal(Object{"env": "prod", "az_count": 3.0, "subnets": []interface{}{"10.0.1.0/24", "10.0.2.0/24"}}))
+
+	jsonFile := filepath.Join(dir, "dev.json")
+	Expect(os.WriteFile(jsonFile, []byte(`{"env": "dev", "tags": {"team": "infra"}}`), 0644)).To(Succeed())
+	o, err = LoadVarFile(jsonFile)
+	Expect(err).To(BeNil())
+	Expect(o).To(Equal(Object{"env": "dev", "tags": Object{"team": "infra"}}))
+
+	listFile := filepath.Join(dir, "list.json")
+	Expect(os.WriteFile(listFile, []byte(`[1, 2]`), 0644)).To(Succeed())
+	_, err = LoadVarFile(listFile)
+	Expect(err).ToNot(BeNil())
+
+	_, err = LoadVarFile(filepath.Join(dir, "vars.toml"))
+	Expect(err).ToNot(BeNil())
+
+	key, value, err := ParseVar("cidr=10.0.0.0/16")
+	Expect(err).To(BeNil())
+	Expect(key).To(Equal("cidr"))
+	Expect(value).To(Equal("10.0.0.0/16"))
+	_, _, err = ParseVar("cidr")
+	Expect(err).ToNot(BeNil())
+}
@@ -0,0 +1,173 @@
+package luatest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// expectModule implements the "expect" module of the tests. Failed expectations raise an error at the line of the test.
+type expectModule struct {
+	provider *countingProvider
+}
+
+func (e *expectModule) Loader(L *lua.LState) int {
+	mod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"equal":      e.equal,
+		"not_equal":  e.notEqual,
+		"deep_equal": e.deepEqual,
+		"ok":         e.ok,
+		"no_error":   e.noError,
+		"error":      e.error,
+		"calls":      e.calls,
+		"called":     e.called,
+		"fail":       e.fail,
+	})
+	L.Push(mod)
+	return 1
+}
+
+// equal(actual, expected, [message]) compares with ==: tables must be the same table.
+func (e *expectModule) equal(L *lua.LState) int {
+	actual, expected := L.Get(1), L.Get(2)
+	if !L.Equal(actual, expected) {
+		failf(L, 3, "expect.equal: expected %s, got %s", format(expected), format(actual))
+	}
+	return 0
+}
+
+// not_equal(actual, unexpected, [message])
+func (e *expectModule) notEqual(L *lua.LState) int {
+	actual, unexpected := L.Get(1), L.Get(2)
+	if L.Equal(actual, unexpected) {
+		failf(L, 3, "expect.not_equal: got %s", format(actual))
+	}
+	return 0
+}
+
+// deep_equal(actual, expected, [message]) compares tables key by key.
+func (e *expectModule) deepEqual(L *lua.LState) int {
+	actual, expected := L.Get(1), L.Get(2)
+	if !deepEqual(actual, expected) {
+		failf(L, 3, "expect.deep_equal: expected %s, got %s", format(expected), format(actual))
+	}
+	return 0
+}
+
+// ok(value, [message]) expects a value other than nil and false.
+func (e *expectModule) ok(L *lua.LState) int {
+	if !lua.LVAsBool(L.Get(1)) {
+		failf(L, 2, "expect.ok: got %s", format(L.Get(1)))
+	}
+	return 0
+}
+
+// no_error(err, [message])
+func (e *expectModule) noError(L *lua.LState) int {
+	if err := L.Get(1); err != lua.LNil {
+		failf(L, 2, "expect.no_error: got %s", format(err))
+	}
+	return 0
+}
+
+// error(err, [kind], [message]) expects an error. The kind is a part of the error like
+// "DependencyViolation", "InvalidSubnet.Conflict", "invalid input" or "not found".
+func (e *expectModule) error(L *lua.LState) int {
+	err := L.Get(1)
+	if err == lua.LNil {
+		failf(L, 3, "expect.error: got no error")
+		return 0
+	}
+	if kind := L.OptString(2, ""); kind != "" && !strings.Contains(err.String(), kind) {
+		failf(L, 3, "expect.error: expected an error %q, got %s", kind, format(err))
+	}
+	return 0
+}
+
+// calls(verb, [resource]) returns the number of calls of the aws module, e.g. calls("create", "aws_vpc").
+// Operations of aws.call are counted by service and operation: calls("call", "ec2.DescribeVpcs"),
+// and find_by_tag by searched type: calls("find_by_tag", "aws_vpc").
+func (e *expectModule) calls(L *lua.LState) int {
+	verb := checkVerb(L, 1)
+	L.Push(lua.LNumber(e.provider.count(verb, L.OptString(2, ""))))
+	return 1
+}
+
+// called(verb, resource, n, [message]) expects n calls.
+func (e *expectModule) called(L *lua.LState) int {
+	verb, resource, n := checkVerb(L, 1), L.CheckString(2), L.CheckInt(3)
+	if count := e.provider.count(verb, resource); count != n {
+		failf(L, 4, "expect.called: expected %d calls of %s %s, got %d", n, verb, resource, count)
+	}
+	return 0
+}
+
+// checkVerb raises an error when the argument is not a verb of the aws module, which would never be counted.
+func checkVerb(L *lua.LState, n int) string {
+	verb := L.CheckString(n)
+	if !countedVerbs[verb] {
+		L.ArgError(n, fmt.Sprintf("unknown verb %q", verb))
+	}
+	return verb
+}
+
+// fail(message)
+func (e *expectModule) fail(L *lua.LState) int {
+	L.Error(lua.LString(L.OptString(1, "expect.fail")), 2)
+	return 0
+}
+
+// failf raises the failure at the line of the test. The optional message of the caller is prepended.
+func failf(L *lua.LState, messageIdx int, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if m := L.OptString(messageIdx, ""); m != "" {
+		message = m + ": " + message
+	}
+	L.Error(lua.LString(message), 2)
+}
+
+func deepEqual(a, b lua.LValue) bool {
+	ta, okA := a.(*lua.LTable)
+	tb, okB := b.(*lua.LTable)
+	if !okA || !okB {
+		return a.Type() == b.Type() && a.String() == b.String()
+	}
+	if ta == tb {
+		return true
+	}
+
+	equal := true
+	count := 0
+	ta.ForEach(func(k, v lua.LValue) {
+		count++
+		if equal && !deepEqual(v, tb.RawGet(k)) {
+			equal = false
+		}
+	})
+	tb.ForEach(func(_, _ lua.LValue) {
+		count--
+	})
+	return equal && count == 0
+}
+
+// format returns a readable representation of a value: tables are printed with their sorted keys.
+func format(v lua.LValue) string {
+	switch value := v.(type) {
+	case lua.LString:
+		return fmt.Sprintf("%q", string(value))
+	case *lua.LTable:
+		items := []string{}
+		value.ForEach(func(k, item lua.LValue) {
+			key := k.String()
+			if _, isNumber := k.(lua.LNumber); isNumber {
+				key = "[" + key + "]"
+			}
+			items = append(items, key+" = "+format(item))
+		})
+		sort.Strings(items)
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+	return v.String()
+}
@@ -0,0 +1,92 @@
+package luatest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as a JUnit XML report with one test suite per file.
+func WriteJUnit(w io.Writer, results []Result) error {
+	report := junitSuites{}
+	index := map[string]int{}
+	for _, r := range results {
+		i, found := index[r.File]
+		if !found {
+			i = len(report.Suites)
+			index[r.File] = i
+			report.Suites = append(report.Suites, junitSuite{Name: r.File})
+		}
+		suite := &report.Suites[i]
+
+		c := junitCase{Name: r.Name, Classname: r.File, Time: seconds(r.Duration.Seconds())}
+		if c.Name == "" {
+			c.Name = "load"
+		}
+		if r.Failure != "" {
+			c.Failure = &junitFailure{Message: firstLine(r.Failure), Text: r.Failure}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	for i := range report.Suites {
+		total := 0.0
+		for _, r := range results {
+			if r.File == report.Suites[i].Name {
+				total += r.Duration.Seconds()
+			}
+		}
+		report.Suites[i].Time = seconds(total)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
+
+func firstLine(s string) string {
+	for i, c := range s {
+		if c == '\n' {
+			return s[:i]
+		}
+	}
+	return s
+}
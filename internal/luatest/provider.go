@@ -0,0 +1,91 @@
+package luatest
+
+import (
+	"context"
+
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+// countedVerbs are the verbs of the aws module counted by the provider.
+var countedVerbs = map[string]bool{
+	"create": true, "get": true, "update": true, "delete": true, "list": true,
+	"call": true, "tag": true, "untag": true, "find_by_tag": true, "define": true,
+}
+
+// countingProvider counts the calls of the aws module before passing them to the provider.
+type countingProvider struct {
+	lua.AwsProvider
+	calls map[[2]string]int
+	// total counts the calls by verb: a call of find_by_tag is counted once for each of its types but once in total.
+	total map[string]int
+}
+
+func newCountingProvider(p lua.AwsProvider) *countingProvider {
+	return &countingProvider{AwsProvider: p, calls: make(map[[2]string]int), total: make(map[string]int)}
+}
+
+func (c *countingProvider) record(verb string, resources ...string) {
+	c.total[verb]++
+	for _, resource := range resources {
+		c.calls[[2]string{verb, resource}]++
+	}
+}
+
+// count returns the number of calls of the verb on the resource, or on any resource when the resource is empty.
+func (c *countingProvider) count(verb, resource string) int {
+	if resource != "" {
+		return c.calls[[2]string{verb, resource}]
+	}
+	return c.total[verb]
+}
+
+func (c *countingProvider) Create(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	c.record("create", resource)
+	return c.AwsProvider.Create(ctx, resource, o)
+}
+
+func (c *countingProvider) Get(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	c.record("get", resource)
+	return c.AwsProvider.Get(ctx, resource, o)
+}
+
+func (c *countingProvider) Update(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	c.record("update", resource)
+	return c.AwsProvider.Update(ctx, resource, o)
+}
+
+func (c *countingProvider) Delete(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	c.record("delete", resource)
+	return c.AwsProvider.Delete(ctx, resource, o)
+}
+
+func (c *countingProvider) List(ctx context.Context, resource string, o lua.Object) ([]lua.Object, error) {
+	c.record("list", resource)
+	return c.AwsProvider.List(ctx, resource, o)
+}
+
+func (c *countingProvider) Call(ctx context.Context, service, operation string, o lua.Object) (lua.Object, error) {
+	c.record("call", service+"."+operation)
+	return c.AwsProvider.Call(ctx, service, operation, o)
+}
+
+func (c *countingProvider) Tag(ctx context.Context, id string, tags map[string]string) error {
+	c.record("tag", id)
+	return c.AwsProvider.Tag(ctx, id, tags)
+}
+
+func (c *countingProvider) Untag(ctx context.Context, id string, keys []string) error {
+	c.record("untag", id)
+	return c.AwsProvider.Untag(ctx, id, keys)
+}
+
+// FindByTag counts the call for each of the searched types.
+func (c *countingProvider) FindByTag(ctx context.Context, tags map[string]string, resourceTypes []string) ([]lua.Object, error) {
+	c.record("find_by_tag", resourceTypes...)
+	return c.AwsProvider.FindByTag(ctx, tags, resourceTypes)
+}
+
+func (c *countingProvider) Define(def lua.ResourceDefinition) error {
+	c.record("define", def.Name)
+	return c.AwsProvider.Define(def)
+}
@@ -0,0 +1,193 @@
+// Package luatest runs the tests of lua scripts.
+//
+// Tests are the global functions named test_* of the *_test.lua files. Each test runs in a fresh lua state,
+// with its own provider, after the file has been loaded. The "expect" module checks the results.
+package luatest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tupyy/aws-lua/internal/lua"
+	glua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// ProviderFunc returns the provider of a test. The done function is called after the test and fails it
+// when it returns an error (e.g. recorded requests which were not replayed).
+type ProviderFunc func(file, test string) (p lua.AwsProvider, done func() error, err error)
+
+type Runner struct {
+	Provider ProviderFunc
+	// Preload preloads the other modules of the scripts.
+	Preload func(L *glua.LState)
+	Out     io.Writer
+}
+
+// Result is the result of a test. Failure is empty when the test passed.
+type Result struct {
+	File     string
+	Name     string
+	Duration time.Duration
+	Failure  string
+}
+
+// Discover returns the *_test.lua files of the directory and its subdirectories.
+func Discover(dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), "_test.lua") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// Run runs the tests of the files and prints their results.
+func (r *Runner) Run(files []string) []Result {
+	results := []Result{}
+	for _, file := range files {
+		names, err := testNames(file)
+		if err != nil {
+			results = append(results, r.report(Result{File: file, Failure: err.Error()}))
+			continue
+		}
+		for _, name := range names {
+			start := time.Now()
+			err := r.runTest(file, name)
+			result := Result{File: file, Name: name, Duration: time.Since(start)}
+			if err != nil {
+				result.Failure = err.Error()
+			}
+			results = append(results, r.report(result))
+		}
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Failure != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(r.Out, "FAIL: %d of %d tests failed in %d files\n", failed, len(results), len(files))
+	} else {
+		fmt.Fprintf(r.Out, "PASS: %d tests in %d files\n", len(results), len(files))
+	}
+	return results
+}
+
+func (r *Runner) runTest(file, name string) (err error) {
+	provider, done, err := r.Provider(file, name)
+	if err != nil {
+		return err
+	}
+	if done != nil {
+		// done runs even when the test fails, its error is reported when the test passed.
+		defer func() {
+			if doneErr := done(); err == nil {
+				err = doneErr
+			}
+		}()
+	}
+	counting := newCountingProvider(provider)
+
+	L := glua.NewState()
+	defer L.Close()
+	L.PreloadModule("aws", lua.NewAwsModule(counting).Loader)
+	L.PreloadModule("expect", (&expectModule{provider: counting}).Loader)
+	if r.Preload != nil {
+		r.Preload(L)
+	}
+
+	if err := L.DoFile(file); err != nil {
+		return luaError(err)
+	}
+	if err := L.CallByParam(glua.P{Fn: L.GetGlobal(name), NRet: 0, Protect: true}); err != nil {
+		return luaError(err)
+	}
+	return nil
+}
+
+func (r *Runner) report(result Result) Result {
+	name := result.File
+	if result.Name != "" {
+		name += ":" + result.Name
+	}
+	if result.Failure == "" {
+		fmt.Fprintf(r.Out, "--- PASS: %s (%.2fs)\n", name, result.Duration.Seconds())
+		return result
+	}
+	fmt.Fprintf(r.Out, "--- FAIL: %s (%.2fs)\n", name, result.Duration.Seconds())
+	for _, line := range strings.Split(result.Failure, "\n") {
+		fmt.Fprintf(r.Out, "    %s\n", line)
+	}
+	return result
+}
+
+// testNames returns the names of the global test_* functions of the file in their order of declaration.
+func testNames(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	chunk, err := parse.Parse(f, file)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, stmt := range chunk {
+		switch s := stmt.(type) {
+		case *ast.FuncDefStmt:
+			if ident, ok := s.Name.Func.(*ast.IdentExpr); ok && s.Name.Receiver == nil {
+				names = append(names, ident.Value)
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range s.Lhs {
+				ident, ok := lhs.(*ast.IdentExpr)
+				if !ok || i >= len(s.Rhs) {
+					continue
+				}
+				if _, isFunc := s.Rhs[i].(*ast.FunctionExpr); isFunc {
+					names = append(names, ident.Value)
+				}
+			}
+		}
+	}
+
+	tests := names[:0]
+	for _, name := range names {
+		if strings.HasPrefix(name, "test_") {
+			tests = append(tests, name)
+		}
+	}
+	if len(tests) == 0 {
+		return nil, fmt.Errorf("no test_* function found")
+	}
+	return tests, nil
+}
+
+// luaError returns the message of a lua error without its stack trace.
+func luaError(err error) error {
+	var apiErr *glua.ApiError
+	if errors.As(err, &apiErr) && apiErr.Object != nil {
+		return errors.New(apiErr.Object.String())
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package luatest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/fake"
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+const vpcTest = `
+local aws = require("aws")
+local expect = require("expect")
+
+-- top level code runs before every test, in a fresh state.
+count = (count or 0) + 1
+
+function test_create_vpc()
+    local vpc, err = aws.create("aws_vpc", { cidr = "10.0.0.0/16", tags = { env = "dev" } })
+    expect.no_error(err)
+    expect.equal(vpc.state, "available")
+    expect.deep_equal(vpc.tags, { env = "dev" })
+    expect.called("create", "aws_vpc", 1)
+    expect.equal(count, 1)
+
+    aws.find_by_tag({ env = "dev" }, { types = { "aws_vpc", "aws_subnet" } })
+    expect.called("find_by_tag", "aws_vpc", 1)
+    expect.equal(expect.calls("find_by_tag"), 1)
+    expect.equal(pcall(expect.calls, "find"), false)
+end
+
+function test_dependency()
+    local vpc = aws.create("aws_vpc", { cidr = "10.0.0.0/16" })
+    aws.create("aws_subnet", { vpc_id = vpc.id, cidr = "10.0.1.0/24" })
+    local _, err = aws.delete("aws_vpc", { id = vpc.id })
+    expect.error(err, "DependencyViolation")
+    expect.equal(expect.calls("create"), 2)
+end
+
+test_failing = function()
+    expect.equal(#aws.list("aws_vpc", {}), 1, "vpcs")
+end
+
+local function helper() end
+`
+
+func TestRunner(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+	Expect(os.MkdirAll(filepath.Join(dir, "network"), 0755)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, "network", "vpc_test.lua"), []byte(vpcTest), 0644)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, "empty_test.lua"), []byte("local x = 1"), 0644)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, "helper.lua"), []byte("function test_ignored() end"), 0644)).To(Succeed())
+
+	files, err := Discover(dir)
+	Expect(err).To(BeNil())
+	Expect(files).To(HaveLen(2))
+
+	out := &strings.Builder{}
+	tests := []string{}
+	done := []string{}
+	r := Runner{
+		Provider: func(file, test string) (lua.AwsProvider, func() error, error) {
+			tests = append(tests, test)
+			return fake.New("eu-west-1"), func() error {
+				done = append(done, test)
+				return nil
+			}, nil
+		},
+		Out: out,
+	}
+	results := r.Run(files)
+	Expect(tests).To(Equal([]string{"test_create_vpc", "test_dependency", "test_failing"}))
+	// done is called after the failing tests too.
+	Expect(done).To(Equal(tests))
+	Expect(results).To(HaveLen(4))
+
+	Expect(results[0].Failure).To(Equal("no test_* function found"))
+	Expect(results[1].Failure).To(BeEmpty())
+	Expect(results[2].Failure).To(BeEmpty())
+	Expect(results[3].Name).To(Equal("test_failing"))
+	Expect(results[3].Failure).To(HaveSuffix("vpc_test.lua:31: vpcs: expect.equal: expected 1, got 0"))
+	Expect(out.String()).To(ContainSubstring("FAIL: 2 of 4 tests failed in 2 files"))
+
+	report := &strings.Builder{}
+	Expect(WriteJUnit(report, results)).To(Succeed())
+	Expect(report.String()).To(ContainSubstring(`<testsuite name="` + files[1] + `" tests="3" failures="1"`))
+	Expect(report.String()).To(ContainSubstring(`<failure message=`))
+}
@@ -0,0 +1,93 @@
+package repl
+
+import (
+	"sort"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// completer completes the resource types inside strings, e.g. aws.list("aws_s<tab>, and the globals
+// and the fields of the tables elsewhere, e.g. aws.cr<tab>.
+type completer struct {
+	L     *lua.LState
+	types func() []string
+}
+
+func (c *completer) complete(line string, pos int) (int, []string) {
+	runes := []rune(line)
+	start := pos
+	for start > 0 && isWordRune(runes[start-1]) {
+		start--
+	}
+	word := string(runes[start:pos])
+
+	if start > 0 && (runes[start-1] == '"' || runes[start-1] == '\'') && inString(runes[:start]) {
+		return start, withPrefix(c.types(), word)
+	}
+
+	// the fields of the table before the last separator
+	sep := strings.LastIndexAny(word, ".:")
+	if sep < 0 {
+		return start, withPrefix(keys(c.L.G.Global), word)
+	}
+	var value lua.LValue = c.L.G.Global
+	for _, name := range strings.FieldsFunc(word[:sep], func(r rune) bool { return r == '.' || r == ':' }) {
+		t, ok := value.(*lua.LTable)
+		if !ok {
+			return start, nil
+		}
+		value = t.RawGetString(name)
+	}
+	t, ok := value.(*lua.LTable)
+	if !ok {
+		return start, nil
+	}
+	candidates := []string{}
+	for _, k := range withPrefix(keys(t), word[sep+1:]) {
+		candidates = append(candidates, word[:sep+1]+k)
+	}
+	return start, candidates
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '.' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// inString returns true when the line, which ends with a quote, ends inside a string.
+func inString(runes []rune) bool {
+	var quote rune
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case quote == 0 && (runes[i] == '"' || runes[i] == '\''):
+			quote = runes[i]
+		case quote != 0 && runes[i] == '\\':
+			i++
+		case quote != 0 && runes[i] == quote:
+			quote = 0
+		}
+	}
+	return quote != 0
+}
+
+// keys returns the string keys of the table, which are identifiers.
+func keys(t *lua.LTable) []string {
+	list := []string{}
+	t.ForEach(func(k, _ lua.LValue) {
+		if s, ok := k.(lua.LString); ok && identifier.MatchString(string(s)) {
+			list = append(list, string(s))
+		}
+	})
+	return list
+}
+
+func withPrefix(words []string, prefix string) []string {
+	list := []string{}
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			list = append(list, w)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
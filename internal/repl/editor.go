@@ -0,0 +1,219 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// maxHistory is the number of lines kept in the history.
+const maxHistory = 1000
+
+// errInterrupted is returned by readLine when the line is cancelled with Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// completeFunc returns the position where the word being completed starts and its completions.
+type completeFunc func(line string, pos int) (int, []string)
+
+// editor reads lines from a terminal in raw mode, with the usual key bindings:
+// arrows, home/end, Ctrl-A/E/B/F, Ctrl-K/U/W, history with up/down and Ctrl-P/N, completion with tab,
+// Ctrl-C to cancel the line and Ctrl-D to stop on an empty line.
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  []string
+	complete completeFunc
+
+	line []rune
+	pos  int
+}
+
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// readLine reads a line. The terminal must be in raw mode.
+func (e *editor) readLine(prompt string) (string, error) {
+	e.line, e.pos = nil, 0
+	// index in the history of the shown line; the line being typed is saved when browsing the history.
+	index, typed := len(e.history), ""
+	e.refresh(prompt)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(e.line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(e.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteRune()
+		case 127, 8: // backspace
+			if e.pos > 0 {
+				e.pos--
+				e.deleteRune()
+			}
+		case 1: // Ctrl-A
+			e.pos = 0
+		case 5: // Ctrl-E
+			e.pos = len(e.line)
+		case 2: // Ctrl-B
+			e.move(-1)
+		case 6: // Ctrl-F
+			e.move(1)
+		case 11: // Ctrl-K
+			e.line = e.line[:e.pos]
+		case 21: // Ctrl-U
+			e.line = append([]rune{}, e.line[e.pos:]...)
+			e.pos = 0
+		case 23: // Ctrl-W
+			start := e.pos
+			for start > 0 && e.line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.line[start-1] != ' ' {
+				start--
+			}
+			e.line = append(e.line[:start], e.line[e.pos:]...)
+			e.pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			index, typed = e.browse(index, index-1, typed)
+		case 14: // Ctrl-N
+			index, typed = e.browse(index, index+1, typed)
+		case '\t':
+			e.completeWord(prompt)
+		case 27: // escape sequences of the arrows and the home, end and delete keys
+			switch e.readEscape() {
+			case "[A", "OA":
+				index, typed = e.browse(index, index-1, typed)
+			case "[B", "OB":
+				index, typed = e.browse(index, index+1, typed)
+			case "[C", "OC":
+				e.move(1)
+			case "[D", "OD":
+				e.move(-1)
+			case "[H", "OH", "[1~":
+				e.pos = 0
+			case "[F", "OF", "[4~":
+				e.pos = len(e.line)
+			case "[3~":
+				e.deleteRune()
+			}
+		default:
+			if r >= ' ' {
+				e.line = append(e.line[:e.pos], append([]rune{r}, e.line[e.pos:]...)...)
+				e.pos++
+			}
+		}
+		e.refresh(prompt)
+	}
+}
+
+// readEscape reads the rest of an escape sequence, e.g. "[A" for the up arrow.
+func (e *editor) readEscape() string {
+	seq := []rune{}
+	for len(seq) < 8 {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			break
+		}
+		seq = append(seq, r)
+		// a sequence ends with a letter or ~, after its introducer
+		if len(seq) > 1 && (r == '~' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')) {
+			break
+		}
+	}
+	return string(seq)
+}
+
+// browse shows the history line to, saving the line being typed when leaving it.
+func (e *editor) browse(from, to int, typed string) (int, string) {
+	if to < 0 || to > len(e.history) {
+		return from, typed
+	}
+	if from == len(e.history) {
+		typed = string(e.line)
+	}
+	if to == len(e.history) {
+		e.line = []rune(typed)
+	} else {
+		e.line = []rune(e.history[to])
+	}
+	e.pos = len(e.line)
+	return to, typed
+}
+
+func (e *editor) move(n int) {
+	if p := e.pos + n; p >= 0 && p <= len(e.line) {
+		e.pos = p
+	}
+}
+
+func (e *editor) deleteRune() {
+	if e.pos < len(e.line) {
+		e.line = append(e.line[:e.pos], e.line[e.pos+1:]...)
+	}
+}
+
+// completeWord completes the word before the cursor with the longest common prefix of its completions,
+// and lists them when there is nothing to add.
+func (e *editor) completeWord(prompt string) {
+	if e.complete == nil {
+		return
+	}
+	start, candidates := e.complete(string(e.line[:e.pos]), e.pos)
+	if len(candidates) == 0 {
+		return
+	}
+
+	word := string(e.line[start:e.pos])
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(word) {
+		e.line = append(e.line[:start], append([]rune(prefix), e.line[e.pos:]...)...)
+		e.pos = start + len([]rune(prefix))
+		return
+	}
+	if len(candidates) > 1 {
+		sort.Strings(candidates)
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+// refresh redraws the line and puts the cursor at its position.
+func (e *editor) refresh(prompt string) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(e.line))
+	if n := len(e.line) - e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,103 @@
+package repl
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// maxInline is the length under which a table is printed on one line.
+const maxInline = 72
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// pretty formats the value like a lua literal. Tables are printed with sorted keys, on one line when they are short.
+func pretty(v lua.LValue) string {
+	return format(v, "", map[*lua.LTable]bool{})
+}
+
+func format(v lua.LValue, indent string, seen map[*lua.LTable]bool) string {
+	switch value := v.(type) {
+	case lua.LString:
+		return strconv.Quote(string(value))
+	case *lua.LTable:
+		if seen[value] {
+			return "<cycle>"
+		}
+		seen[value] = true
+		defer delete(seen, value)
+		return formatTable(value, indent, seen)
+	}
+	return v.String()
+}
+
+func formatTable(t *lua.LTable, indent string, seen map[*lua.LTable]bool) string {
+	keys := []lua.LValue{}
+	t.ForEach(func(k, _ lua.LValue) {
+		keys = append(keys, k)
+	})
+	if len(keys) == 0 {
+		return "{}"
+	}
+
+	// the array part is printed without keys, in order
+	n := t.MaxN()
+	if n != len(keys) {
+		n = 0
+	}
+	if n == 0 {
+		sortKeys(keys)
+	}
+
+	items := make([]string, 0, len(keys))
+	for i, k := range keys {
+		if n > 0 {
+			k = lua.LNumber(i + 1)
+		}
+		item := format(t.RawGet(k), indent+"  ", seen)
+		if n == 0 {
+			item = formatKey(k) + " = " + item
+		}
+		items = append(items, item)
+	}
+
+	inline := "{ " + strings.Join(items, ", ") + " }"
+	if len(indent)+len(inline) <= maxInline && !strings.Contains(inline, "\n") {
+		return inline
+	}
+	return "{\n" + indent + "  " + strings.Join(items, ",\n"+indent+"  ") + ",\n" + indent + "}"
+}
+
+func formatKey(k lua.LValue) string {
+	if s, ok := k.(lua.LString); ok && identifier.MatchString(string(s)) {
+		return string(s)
+	}
+	return fmt.Sprintf("[%s]", format(k, "", map[*lua.LTable]bool{}))
+}
+
+// sortKeys sorts the numbers before the strings, then the other keys by their string.
+func sortKeys(keys []lua.LValue) {
+	rank := func(k lua.LValue) int {
+		switch k.(type) {
+		case lua.LNumber:
+			return 0
+		case lua.LString:
+			return 1
+		}
+		return 2
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, rj := rank(keys[i]), rank(keys[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if ni, ok := keys[i].(lua.LNumber); ok {
+			return ni < keys[j].(lua.LNumber)
+		}
+		return keys[i].String() < keys[j].String()
+	})
+}
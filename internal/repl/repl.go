@@ -0,0 +1,164 @@
+// Package repl implements the interactive shell of aws-lua.
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = ">> "
+)
+
+// errIncomplete is returned by compile when the chunk continues on the next line.
+var errIncomplete = errors.New("incomplete chunk")
+
+// Repl reads lua statements or expressions and prints their results.
+// A statement spanning several lines is read until it is complete.
+// When In is a terminal, the lines are edited with the editor, else they are read as they come.
+type Repl struct {
+	L   *lua.LState
+	In  io.Reader
+	Out io.Writer
+	// HistoryFile is the file where the history is loaded from and saved to. The history is not saved when empty.
+	HistoryFile string
+	// Types returns the resource types completed in strings.
+	Types func() []string
+}
+
+// Run reads and evaluates until the end of the input or Ctrl-D.
+func (r *Repl) Run() error {
+	e := &editor{
+		in:       bufio.NewReader(r.In),
+		out:      r.Out,
+		history:  r.loadHistory(),
+		complete: (&completer{L: r.L, types: r.Types}).complete,
+	}
+	f, isFile := r.In.(*os.File)
+
+	read := func(p string) (string, error) {
+		if isFile {
+			if restore, err := makeRaw(f.Fd()); err == nil {
+				defer restore()
+				return e.readLine(p)
+			}
+		}
+		// not a terminal
+		fmt.Fprint(r.Out, p)
+		line, err := e.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		if err == io.EOF {
+			fmt.Fprintln(r.Out)
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	chunk := ""
+	for {
+		p := prompt
+		if chunk != "" {
+			p = continuationPrompt
+		}
+		line, err := read(p)
+		if errors.Is(err, errInterrupted) {
+			chunk = ""
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				return err
+			}
+			break
+		}
+		e.addHistory(line)
+
+		chunk += line + "\n"
+		fn, err := r.compile(chunk)
+		if errors.Is(err, errIncomplete) {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(r.Out, strings.TrimSpace(err.Error()))
+			chunk = ""
+			continue
+		}
+		chunk = ""
+		r.eval(fn)
+	}
+
+	return r.saveHistory(e.history)
+}
+
+// compile compiles the chunk as an expression whose values are printed, or else as statements.
+// It returns errIncomplete when the chunk ends before the expression or the statement.
+func (r *Repl) compile(chunk string) (*lua.LFunction, error) {
+	fn, exprErr := r.L.Load(strings.NewReader("return "+chunk), "stdin")
+	if exprErr == nil {
+		return fn, nil
+	}
+	fn, err := r.L.Load(strings.NewReader(chunk), "stdin")
+	if err != nil && (incomplete(exprErr) || incomplete(err)) {
+		return nil, errIncomplete
+	}
+	return fn, err
+}
+
+func incomplete(err error) bool {
+	return strings.Contains(err.Error(), "at EOF:") && !strings.Contains(err.Error(), "unterminated string")
+}
+
+// eval calls the function and prints its results, or its error.
+func (r *Repl) eval(fn *lua.LFunction) {
+	top := r.L.GetTop()
+	defer r.L.SetTop(top)
+
+	r.L.Push(fn)
+	if err := r.L.PCall(0, lua.MultRet, nil); err != nil {
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			fmt.Fprintln(r.Out, apiErr.Object.String())
+			return
+		}
+		fmt.Fprintln(r.Out, err)
+		return
+	}
+	for i := top + 1; i <= r.L.GetTop(); i++ {
+		fmt.Fprintln(r.Out, pretty(r.L.Get(i)))
+	}
+}
+
+func (r *Repl) loadHistory() []string {
+	if r.HistoryFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(r.HistoryFile)
+	if err != nil {
+		return nil
+	}
+	history := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			history = append(history, line)
+		}
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	return history
+}
+
+func (r *Repl) saveHistory(history []string) error {
+	if r.HistoryFile == "" || len(history) == 0 {
+		return nil
+	}
+	return os.WriteFile(r.HistoryFile, []byte(strings.Join(history, "\n")+"\n"), 0600)
+}
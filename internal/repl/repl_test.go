@@ -0,0 +1,111 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestRun(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	history := filepath.Join(t.TempDir(), "history")
+	Expect(os.WriteFile(history, []byte("x = 1\n"), 0600)).To(Succeed())
+
+	input := `function double(a)
+  return a * 2
+end
+double(21), "s"
+{ b = { 1, 2 }, a = "x", ["key with space"] = true }
+1 +
+2
+x = = 1
+error("boom")
+`
+	var out bytes.Buffer
+	r := Repl{L: L, In: strings.NewReader(input), Out: &out, HistoryFile: history}
+	Expect(r.Run()).To(Succeed())
+
+	Expect(out.String()).To(Equal(`> >> >> > 42
+"s"
+> { a = "x", b = { 1, 2 }, ["key with space"] = true }
+> >> 3
+> stdin line:1(column:5) near '=':   syntax error
+> stdin:1: boom
+> 
+`))
+
+	data, err := os.ReadFile(history)
+	Expect(err).To(BeNil())
+	Expect(strings.Split(string(data), "\n")).To(HaveLen(11))
+	Expect(string(data)).To(HavePrefix("x = 1\nfunction double(a)\n"))
+}
+
+func TestPretty(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	Expect(L.DoString(`
+t = { id = "vpc-1", tags = { env = "dev", owner = "infra" }, subnets = { "subnet-1", "subnet-2" }, cidr_block = "10.0.0.0/16", state = "available" }
+t.self = t
+`)).To(Succeed())
+
+	Expect(pretty(L.GetGlobal("t"))).To(Equal(`{
+  cidr_block = "10.0.0.0/16",
+  id = "vpc-1",
+  self = <cycle>,
+  state = "available",
+  subnets = { "subnet-1", "subnet-2" },
+  tags = { env = "dev", owner = "infra" },
+}`))
+	Expect(pretty(L.NewTable())).To(Equal("{}"))
+	Expect(pretty(lua.LNumber(1.5))).To(Equal("1.5"))
+}
+
+func TestEditor(t *testing.T) {
+	RegisterTestingT(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	Expect(L.DoString(`aws = { create = 1, list = 2, list_all = 3 }`)).To(Succeed())
+	c := &completer{L: L, types: func() []string { return []string{"aws_subnet", "aws_vpc", "aws_vpc_peering"} }}
+
+	read := func(keys string, history ...string) (string, error) {
+		e := &editor{in: bufio.NewReader(strings.NewReader(keys)), out: io.Discard, history: history, complete: c.complete}
+		return e.readLine("> ")
+	}
+
+	line, err := read("aws.cr\t(\"aws_s\t\", {})\r")
+	Expect(err).To(BeNil())
+	Expect(line).To(Equal(`aws.create("aws_subnet", {})`))
+
+	// the common prefix of aws_vpc and aws_vpc_peering, then the list
+	line, _ = read("aws.li\t(\"aws_v\t\t\r")
+	Expect(line).To(Equal(`aws.list("aws_vpc`))
+
+	// left arrow, insert, home, delete, end
+	line, _ = read("ab\x1b[Dx\x01\x1b[3~\x05c\r")
+	Expect(line).To(Equal("xbc"))
+
+	// history: up twice, down once
+	line, _ = read("\x1b[A\x1b[A\x1b[B\r", "first", "second")
+	Expect(line).To(Equal("second"))
+
+	line, _ = read("one two\x17\x15three\r")
+	Expect(line).To(Equal("three"))
+
+	_, err = read("abc\x03")
+	Expect(err).To(Equal(errInterrupted))
+	_, err = read("\x04")
+	Expect(err).To(Equal(io.EOF))
+}
@@ -0,0 +1,80 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// memoryInterval is the number of instructions between two samples of the heap.
+const memoryInterval = 100000
+
+// limits is the context of the state. The vm calls Done before each instruction, which counts them
+// and samples the heap.
+type limits struct {
+	context.Context
+	cancel context.CancelFunc
+
+	maxInstructions int64
+	maxMemory       uint64
+	timeout         string
+	instructions    int64
+
+	mu  sync.Mutex
+	err error
+}
+
+func newLimits(opts Options) (*limits, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), opts.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	l := &limits{
+		Context:         ctx,
+		cancel:          cancel,
+		maxInstructions: opts.MaxInstructions,
+		maxMemory:       opts.MaxMemory,
+		timeout:         opts.Timeout.String(),
+	}
+	return l, cancel
+}
+
+func (l *limits) Done() <-chan struct{} {
+	l.instructions++
+	if l.maxInstructions > 0 && l.instructions > l.maxInstructions {
+		l.stop(fmt.Errorf("sandbox: instruction limit of %d exceeded", l.maxInstructions))
+	}
+	if l.maxMemory > 0 && l.instructions%memoryInterval == 0 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > l.maxMemory {
+			l.stop(fmt.Errorf("sandbox: memory limit of %d MB exceeded", l.maxMemory>>20))
+		}
+	}
+	return l.Context.Done()
+}
+
+func (l *limits) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	if l.Context.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("sandbox: time limit of %s exceeded", l.timeout)
+	}
+	return l.Context.Err()
+}
+
+func (l *limits) stop(err error) {
+	l.mu.Lock()
+	if l.err == nil {
+		l.err = err
+	}
+	l.mu.Unlock()
+	l.cancel()
+}
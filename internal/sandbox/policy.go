@@ -0,0 +1,177 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/tupyy/aws-lua/internal/lua"
+)
+
+// verbs are the verbs of the aws module which can be allowed.
+var verbs = map[string]bool{
+	"create": true, "get": true, "update": true, "delete": true, "list": true,
+	"call": true, "tag": true, "untag": true, "find_by_tag": true, "define": true,
+}
+
+type rule struct {
+	pattern string
+	// verbs are the allowed verbs, all of them when nil.
+	verbs map[string]bool
+}
+
+// Policy is the allow-list of the resource types and verbs of a run.
+//
+// A rule is a pattern of resource types followed by the allowed verbs, all of them when omitted:
+//
+//	aws_vpc                  all the verbs on aws_vpc
+//	aws_*:get,list           read the aws resources
+//	ec2.Describe*:call       call the Describe operations of ec2
+//	*:tag,untag,find_by_tag  tag any resource, and search by tag in all the types
+//
+// The operations of aws.call are matched as "service.Operation". tag and untag are matched with the type of
+// the resource, resolved from its id: aws_vpc for vpc-..., aws_user for the ARN of a user. find_by_tag without
+// types is matched with the resource "*", which the pattern "*" matches but not aws_*.
+type Policy struct {
+	rules []rule
+}
+
+// ParsePolicy parses the rules. A nil policy allows everything.
+func ParsePolicy(rules []string) (*Policy, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	p := &Policy{}
+	for _, s := range rules {
+		pattern, list, found := strings.Cut(s, ":")
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, fmt.Errorf("invalid rule %q: bad pattern", s)
+		}
+		r := rule{pattern: pattern}
+		if found {
+			r.verbs = map[string]bool{}
+			for _, verb := range strings.Split(list, ",") {
+				verb = strings.TrimSpace(verb)
+				if !verbs[verb] {
+					return nil, fmt.Errorf("invalid rule %q: unknown verb %q", s, verb)
+				}
+				r.verbs[verb] = true
+			}
+		}
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+// Allowed returns an error when no rule allows the verb on the resource.
+func (p *Policy) Allowed(verb, resource string) error {
+	if p == nil {
+		return nil
+	}
+	for _, r := range p.rules {
+		if matched, _ := path.Match(r.pattern, resource); matched && (r.verbs == nil || r.verbs[verb]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s on %s is not allowed in this run", verb, resource)
+}
+
+// guardedProvider checks the calls of the aws module against the policy before passing them to the provider.
+type guardedProvider struct {
+	lua.AwsProvider
+	policy *Policy
+}
+
+// NewProvider returns the provider restricted to the policy.
+func NewProvider(p lua.AwsProvider, policy *Policy) lua.AwsProvider {
+	if policy == nil {
+		return p
+	}
+	return &guardedProvider{AwsProvider: p, policy: policy}
+}
+
+func (g *guardedProvider) Create(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	if err := g.policy.Allowed("create", resource); err != nil {
+		return lua.Object{}, err
+	}
+	return g.AwsProvider.Create(ctx, resource, o)
+}
+
+func (g *guardedProvider) Get(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	if err := g.policy.Allowed("get", resource); err != nil {
+		return lua.Object{}, err
+	}
+	return g.AwsProvider.Get(ctx, resource, o)
+}
+
+func (g *guardedProvider) Update(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	if err := g.policy.Allowed("update", resource); err != nil {
+		return lua.Object{}, err
+	}
+	return g.AwsProvider.Update(ctx, resource, o)
+}
+
+func (g *guardedProvider) Delete(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	if err := g.policy.Allowed("delete", resource); err != nil {
+		return lua.Object{}, err
+	}
+	return g.AwsProvider.Delete(ctx, resource, o)
+}
+
+func (g *guardedProvider) List(ctx context.Context, resource string, o lua.Object) ([]lua.Object, error) {
+	if err := g.policy.Allowed("list", resource); err != nil {
+		return nil, err
+	}
+	return g.AwsProvider.List(ctx, resource, o)
+}
+
+func (g *guardedProvider) Call(ctx context.Context, service, operation string, o lua.Object) (lua.Object, error) {
+	if err := g.policy.Allowed("call", service+"."+operation); err != nil {
+		return lua.Object{}, err
+	}
+	return g.AwsProvider.Call(ctx, service, operation, o)
+}
+
+func (g *guardedProvider) Tag(ctx context.Context, id string, tags map[string]string) error {
+	if err := g.allowedOn("tag", id); err != nil {
+		return err
+	}
+	return g.AwsProvider.Tag(ctx, id, tags)
+}
+
+func (g *guardedProvider) Untag(ctx context.Context, id string, keys []string) error {
+	if err := g.allowedOn("untag", id); err != nil {
+		return err
+	}
+	return g.AwsProvider.Untag(ctx, id, keys)
+}
+
+// allowedOn checks the verb against the type of the resource of the id. An id whose type is unknown is denied.
+func (g *guardedProvider) allowedOn(verb, id string) error {
+	resource, err := g.AwsProvider.ResourceType(id)
+	if err != nil {
+		return fmt.Errorf("%s on %s is not allowed in this run: %w", verb, id, err)
+	}
+	return g.policy.Allowed(verb, resource)
+}
+
+func (g *guardedProvider) FindByTag(ctx context.Context, tags map[string]string, resourceTypes []string) ([]lua.Object, error) {
+	checked := resourceTypes
+	if len(checked) == 0 {
+		checked = []string{"*"}
+	}
+	for _, resource := range checked {
+		if err := g.policy.Allowed("find_by_tag", resource); err != nil {
+			return nil, err
+		}
+	}
+	return g.AwsProvider.FindByTag(ctx, tags, resourceTypes)
+}
+
+func (g *guardedProvider) Define(def lua.ResourceDefinition) error {
+	if err := g.policy.Allowed("define", def.Name); err != nil {
+		return err
+	}
+	return g.AwsProvider.Define(def)
+}
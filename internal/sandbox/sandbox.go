@@ -0,0 +1,267 @@
+// Package sandbox runs scripts which are not trusted.
+//
+// The state only opens the whitelisted libraries. os is reduced to time, clock, date and difftime;
+// io cannot start processes and, like dofile, loadfile and require, only reaches the files of the
+// allowed directory, whatever package.path is set to. The debug library cannot be opened.
+//
+// The limits are approximations, gopher-lua having no hook on instructions or allocations:
+//   - the instructions are counted by the vm of the main thread, those of coroutines are not counted;
+//   - the memory is the heap of the whole process, sampled every 100000 instructions;
+//   - the wall time stops the script at its next instruction: a call to aws in flight is not interrupted.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// DefaultLibs are the libraries opened when no whitelist is given.
+// coroutine and channel are left out because the instructions of coroutines are not counted.
+var DefaultLibs = []string{"base", "package", "table", "string", "math", "os", "io"}
+
+var libs = map[string]lua.LGFunction{
+	"base":      lua.OpenBase,
+	"package":   lua.OpenPackage,
+	"table":     lua.OpenTable,
+	"string":    lua.OpenString,
+	"math":      lua.OpenMath,
+	"os":        lua.OpenOs,
+	"io":        lua.OpenIo,
+	"coroutine": lua.OpenCoroutine,
+	"channel":   lua.OpenChannel,
+}
+
+// osFuncs are the functions of os kept in the sandbox.
+var osFuncs = []string{"time", "clock", "date", "difftime"}
+
+type Options struct {
+	// Libs is the whitelist of the libraries. DefaultLibs are opened when nil. package is always opened.
+	Libs []string
+	// Dir is the only directory whose files can be read and written. The files cannot be accessed when empty.
+	Dir string
+	// MaxInstructions is the number of instructions after which the script is stopped. 0 means no limit.
+	MaxInstructions int64
+	// MaxMemory is the heap size, in bytes, above which the script is stopped. 0 means no limit.
+	MaxMemory uint64
+	// Timeout is the wall time after which the script is stopped. 0 means no limit.
+	Timeout time.Duration
+}
+
+type sandbox struct {
+	dir string
+}
+
+// NewState returns a state opened with the options, and the function releasing its limits.
+func NewState(opts Options) (*lua.LState, context.CancelFunc, error) {
+	names := opts.Libs
+	if names == nil {
+		names = DefaultLibs
+	}
+	// the modules of aws-lua are required through package.
+	names = append([]string{"package"}, names...)
+	for _, name := range names {
+		if name == "debug" {
+			return nil, nil, errors.New("the debug library cannot be opened in the sandbox")
+		}
+		if _, found := libs[name]; !found {
+			return nil, nil, fmt.Errorf("unknown library %q", name)
+		}
+	}
+
+	s := &sandbox{}
+	if opts.Dir != "" {
+		dir, err := filepath.Abs(opts.Dir)
+		if err == nil {
+			dir, err = filepath.EvalSymlinks(dir)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("sandbox directory: %w", err)
+		}
+		s.dir = dir
+	}
+
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	opened := map[string]bool{}
+	for _, name := range names {
+		if opened[name] {
+			continue
+		}
+		libName := name
+		if name == "base" {
+			libName = lua.BaseLibName
+		}
+		L.Push(L.NewFunction(libs[name]))
+		L.Push(lua.LString(libName))
+		L.Call(1, 0)
+		opened[name] = true
+	}
+
+	if opened["base"] {
+		for _, name := range []string{"dofile", "loadfile"} {
+			L.SetGlobal(name, s.guard(L, L.GetGlobal(name).(*lua.LFunction)))
+		}
+	}
+	if opened["package"] {
+		s.restrictPackage(L)
+	}
+	if opened["os"] {
+		s.restrictOs(L)
+	}
+	if opened["io"] {
+		s.restrictIo(L)
+	}
+
+	ctx, cancel := newLimits(opts)
+	L.SetContext(ctx)
+	return L, cancel, nil
+}
+
+// restrictOs keeps the functions of os which do not reach the system.
+func (s *sandbox) restrictOs(L *lua.LState) {
+	full := L.GetGlobal("os").(*lua.LTable)
+	mod := L.NewTable()
+	for _, name := range osFuncs {
+		mod.RawSetString(name, full.RawGetString(name))
+	}
+	setModule(L, "os", mod)
+}
+
+// restrictPackage replaces the file loader of require, which reads package.path on every call, with one resolving
+// the files in the directory. The files cannot be required when there is no directory.
+func (s *sandbox) restrictPackage(L *lua.LState) {
+	mod := L.GetGlobal("package").(*lua.LTable)
+	loaders := L.GetField(mod, "loaders").(*lua.LTable)
+	path := ""
+	if s.dir != "" {
+		path = filepath.Join(s.dir, "?.lua") + ";" + filepath.Join(s.dir, "?", "init.lua")
+		// the loaders are also referenced by the registry, they are changed in place.
+		loaders.RawSetInt(2, L.NewFunction(s.loadModule))
+	} else {
+		loaders.RawSetInt(2, lua.LNil)
+	}
+	L.SetField(mod, "path", lua.LString(path))
+}
+
+// loadModule finds the module in package.path like the loader of gopher-lua, with the files resolved in the directory.
+func (s *sandbox) loadModule(L *lua.LState) int {
+	name := strings.ReplaceAll(L.CheckString(1), ".", string(filepath.Separator))
+	patterns, ok := L.GetField(L.GetField(L.Get(lua.EnvironIndex), "package"), "path").(lua.LString)
+	if !ok {
+		L.RaiseError("package.path must be a string")
+	}
+	messages := []string{}
+	for _, pattern := range strings.Split(string(patterns), ";") {
+		path, err := s.resolve(strings.ReplaceAll(pattern, "?", name))
+		if err == nil {
+			_, err = os.Stat(path)
+		}
+		if err != nil {
+			messages = append(messages, err.Error())
+			continue
+		}
+		fn, err := L.LoadFile(path)
+		if err != nil {
+			L.RaiseError("%s", err)
+		}
+		L.Push(fn)
+		return 1
+	}
+	L.Push(lua.LString(strings.Join(messages, "\n\t")))
+	return 1
+}
+
+// restrictIo removes popen and tmpfile, and limits the files opened by name to the directory.
+func (s *sandbox) restrictIo(L *lua.LState) {
+	full := L.GetGlobal("io").(*lua.LTable)
+	mod := L.NewTable()
+	full.ForEach(func(k, v lua.LValue) {
+		switch k.String() {
+		case "popen", "tmpfile":
+		case "open", "lines", "input", "output":
+			mod.RawSet(k, s.guard(L, v.(*lua.LFunction)))
+		default:
+			mod.RawSet(k, v)
+		}
+	})
+	setModule(L, "io", mod)
+}
+
+func setModule(L *lua.LState, name string, mod *lua.LTable) {
+	L.SetGlobal(name, mod)
+	if loaded, ok := L.GetField(L.Get(lua.RegistryIndex), "_LOADED").(*lua.LTable); ok {
+		loaded.RawSetString(name, mod)
+	}
+}
+
+// guard returns the function calling fn with its first argument, when it is a file name, resolved in the directory.
+func (s *sandbox) guard(L *lua.LState, fn *lua.LFunction) *lua.LFunction {
+	return L.NewFunction(func(L *lua.LState) int {
+		if name, ok := L.Get(1).(lua.LString); ok {
+			path, err := s.resolve(string(name))
+			if err != nil {
+				L.RaiseError("%s", err)
+			}
+			L.Replace(1, lua.LString(path))
+		}
+		top := L.GetTop()
+		L.Insert(fn, 1)
+		L.Call(top, lua.MultRet)
+		return L.GetTop()
+	})
+}
+
+// resolve returns the real path of the file, relative to the directory, or an error when it is outside of it.
+func (s *sandbox) resolve(name string) (string, error) {
+	if s.dir == "" {
+		return "", fmt.Errorf("sandbox: cannot access %s, the files are disabled", name)
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.dir, path)
+	}
+	// the path is checked before reaching the file system, so that the files outside cannot be probed.
+	path = filepath.Clean(path)
+	if !s.contains(path) {
+		return "", fmt.Errorf("sandbox: cannot access %s, outside of %s", name, s.dir)
+	}
+	path, err := realPath(path)
+	if err != nil {
+		return "", fmt.Errorf("sandbox: %w", err)
+	}
+	if !s.contains(path) {
+		return "", fmt.Errorf("sandbox: cannot access %s, outside of %s", name, s.dir)
+	}
+	return path, nil
+}
+
+func (s *sandbox) contains(path string) bool {
+	return path == s.dir || strings.HasPrefix(path, s.dir+string(filepath.Separator))
+}
+
+// realPath resolves the links of the path. The files which do not exist yet are resolved from their directory.
+func realPath(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return real, nil
+	}
+	parent := filepath.Dir(path)
+	if !errors.Is(err, os.ErrNotExist) || parent == path {
+		return "", err
+	}
+	// a broken link would create its target, wherever it is
+	if _, err := os.Lstat(path); err == nil {
+		return "", fmt.Errorf("%s is a broken link", path)
+	}
+	real, err = realPath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(real, filepath.Base(path)), nil
+}
@@ -0,0 +1,194 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/tupyy/aws-lua/internal/aws"
+	"github.com/tupyy/aws-lua/internal/lua"
+	glua "github.com/yuin/gopher-lua"
+)
+
+func TestLibraries(t *testing.T) {
+	RegisterTestingT(t)
+	dir := t.TempDir()
+	Expect(os.WriteFile(filepath.Join(dir, "mod.lua"), []byte(`return { answer = 42 }`), 0644)).To(Succeed())
+	Expect(os.Symlink("/etc/passwd", filepath.Join(dir, "link"))).To(Succeed())
+	Expect(os.Symlink(filepath.Join(t.TempDir(), "missing"), filepath.Join(dir, "broken"))).To(Succeed())
+	outside := t.TempDir()
+	Expect(os.WriteFile(filepath.Join(outside, "evil.lua"), []byte(`return "evil"`), 0644)).To(Succeed())
+
+	L, cancel, err := NewState(Options{Dir: dir})
+	Expect(err).To(BeNil())
+	defer cancel()
+	defer L.Close()
+
+	script := `
+assert(debug == nil and coroutine == nil)
+assert(os.execute == nil and os.getenv == nil and os.remove == nil and os.exit == nil)
+assert(type(os.time()) == "number")
+assert(io.popen == nil and io.tmpfile == nil)
+
+assert(require("mod").answer == 42)
+local f = assert(io.open("out.txt", "w"))
+f:write("hello")
+f:close()
+assert(io.open("out.txt"):read("*a") == "hello")
+for line in io.lines("out.txt") do assert(line == "hello") end
+
+for _, name in ipairs({ "/etc/passwd", "../out.txt", "link", "broken" }) do
+    local ok, err = pcall(io.open, name, "w")
+    assert(not ok and err:find("sandbox: "), name)
+end
+local ok, err = pcall(dofile, "/etc/passwd")
+assert(not ok and err:find("outside of"), err)
+local ok, err = pcall(loadfile, "../x.lua")
+assert(not ok and err:find("outside of"), err)
+
+-- require reads package.path again on every call.
+package.path = outside .. "/?.lua;../?.lua;/etc/?"
+package.loaded.mod = nil
+for _, name in ipairs({ "evil", "passwd", "mod" }) do
+    local ok, err = pcall(require, name)
+    assert(not ok and err:find("outside of") and not err:find("root:"), err)
+end
+package.path = "?.lua"
+assert(require("mod").answer == 42)
+`
+	L.SetGlobal("outside", glua.LString(outside))
+	Expect(L.DoString(script)).To(Succeed())
+	Expect(filepath.Join(dir, "out.txt")).To(BeAnExistingFile())
+
+	_, _, err = NewState(Options{Libs: []string{"base", "debug"}})
+	Expect(err).ToNot(BeNil())
+	_, _, err = NewState(Options{Libs: []string{"net"}})
+	Expect(err).ToNot(BeNil())
+}
+
+func TestNoFiles(t *testing.T) {
+	RegisterTestingT(t)
+
+	L, cancel, err := NewState(Options{Libs: []string{"base", "io", "coroutine"}})
+	Expect(err).To(BeNil())
+	defer cancel()
+	defer L.Close()
+
+	Expect(L.DoString(`
+assert(coroutine ~= nil and string == nil)
+assert(pcall(require, "x") == false)
+package.path = "/tmp/?.lua"
+assert(pcall(require, "x") == false)
+`)).To(Succeed())
+	Expect(L.DoString(`io.open("x.txt")`)).To(MatchError(ContainSubstring("sandbox: cannot access x.txt, the files are disabled")))
+}
+
+func TestLimits(t *testing.T) {
+	RegisterTestingT(t)
+
+	L, cancel, err := NewState(Options{MaxInstructions: 10000})
+	Expect(err).To(BeNil())
+	defer cancel()
+	Expect(L.DoString(`local n = 0 for i = 1, 100 do n = n + i end`)).To(Succeed())
+	err = L.DoString(`while true do end`)
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("sandbox: instruction limit of 10000 exceeded"))
+	L.Close()
+
+	L, cancel, err = NewState(Options{Timeout: 100 * time.Millisecond})
+	Expect(err).To(BeNil())
+	defer cancel()
+	start := time.Now()
+	err = L.DoString(`while true do end`)
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("sandbox: time limit of 100ms exceeded"))
+	Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	L.Close()
+
+	L, cancel, err = NewState(Options{MaxMemory: 1})
+	Expect(err).To(BeNil())
+	defer cancel()
+	err = L.DoString(`local t = {} for i = 1, 1e6 do t[i] = i end`)
+	Expect(err).ToNot(BeNil())
+	Expect(err.Error()).To(ContainSubstring("sandbox: memory limit"))
+	L.Close()
+}
+
+type nopProvider struct {
+	lua.AwsProvider
+}
+
+func (nopProvider) Create(ctx context.Context, resource string, o lua.Object) (lua.Object, error) {
+	return lua.Object{"id": "1"}, nil
+}
+
+func (nopProvider) List(ctx context.Context, resource string, o lua.Object) ([]lua.Object, error) {
+	return []lua.Object{}, nil
+}
+
+func (nopProvider) Call(ctx context.Context, service, operation string, o lua.Object) (lua.Object, error) {
+	return lua.Object{}, nil
+}
+
+func (nopProvider) FindByTag(ctx context.Context, tags map[string]string, resourceTypes []string) ([]lua.Object, error) {
+	return []lua.Object{}, nil
+}
+
+func (nopProvider) Tag(ctx context.Context, id string, tags map[string]string) error {
+	return nil
+}
+
+func (nopProvider) Untag(ctx context.Context, id string, keys []string) error {
+	return nil
+}
+
+func (nopProvider) ResourceType(id string) (string, error) {
+	return aws.New(aws.ClientConfiguration{}).ResourceType(id)
+}
+
+func TestPolicy(t *testing.T) {
+	RegisterTestingT(t)
+
+	policy, err := ParsePolicy([]string{"aws_vpc", "aws_*:get,list", "ec2.Describe*:call", "aws_subnet:find_by_tag"})
+	Expect(err).To(BeNil())
+	p := NewProvider(nopProvider{}, policy)
+
+	_, err = p.Create(context.TODO(), "aws_vpc", lua.Object{})
+	Expect(err).To(BeNil())
+	_, err = p.Create(context.TODO(), "aws_subnet", lua.Object{})
+	Expect(err).To(MatchError("create on aws_subnet is not allowed in this run"))
+	_, err = p.List(context.TODO(), "aws_subnet", lua.Object{})
+	Expect(err).To(BeNil())
+	_, err = p.Call(context.TODO(), "ec2", "DescribeInstances", lua.Object{})
+	Expect(err).To(BeNil())
+	_, err = p.Call(context.TODO(), "ec2", "TerminateInstances", lua.Object{})
+	Expect(err).ToNot(BeNil())
+	// tag and untag are checked against the type of the resource.
+	Expect(p.Tag(context.TODO(), "vpc-1", map[string]string{"a": "b"})).To(Succeed())
+	Expect(p.Untag(context.TODO(), "vpc-1", []string{"a"})).To(Succeed())
+	Expect(p.Tag(context.TODO(), "subnet-1", map[string]string{"a": "b"})).To(MatchError("tag on aws_subnet is not allowed in this run"))
+	Expect(p.Tag(context.TODO(), "arn:aws:iam::123456789012:user/bob", map[string]string{"a": "b"})).To(MatchError("tag on aws_user is not allowed in this run"))
+	Expect(p.Tag(context.TODO(), "sg-1", map[string]string{"a": "b"})).To(MatchError(`tag on sg-1 is not allowed in this run: unknown resource type of "sg-1"`))
+	_, err = p.FindByTag(context.TODO(), map[string]string{"a": "b"}, []string{"aws_subnet"})
+	Expect(err).To(BeNil())
+	_, err = p.FindByTag(context.TODO(), map[string]string{"a": "b"}, nil)
+	Expect(err).ToNot(BeNil())
+
+	policy, err = ParsePolicy(nil)
+	Expect(err).To(BeNil())
+	Expect(NewProvider(nopProvider{}, policy)).To(Equal(nopProvider{}))
+
+	policy, err = ParsePolicy([]string{"*:tag"})
+	Expect(err).To(BeNil())
+	p = NewProvider(nopProvider{}, policy)
+	Expect(p.Tag(context.TODO(), "arn:aws:iam::123456789012:role/ci", map[string]string{"a": "b"})).To(Succeed())
+	Expect(p.Untag(context.TODO(), "vpc-1", []string{"a"})).To(MatchError("untag on aws_vpc is not allowed in this run"))
+
+	_, err = ParsePolicy([]string{"aws_vpc:destroy"})
+	Expect(err).To(MatchError(`invalid rule "aws_vpc:destroy": unknown verb "destroy"`))
+	_, err = ParsePolicy([]string{"aws_[:list"})
+	Expect(err).ToNot(BeNil())
+}
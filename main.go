@@ -1,12 +1,21 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
 
 	flag "github.com/spf13/pflag"
 	"github.com/tupyy/aws-lua/internal/aws"
+	"github.com/tupyy/aws-lua/internal/cassette"
+	"github.com/tupyy/aws-lua/internal/fake"
+	"github.com/tupyy/aws-lua/internal/logger"
 	"github.com/tupyy/aws-lua/internal/lua"
+	"github.com/tupyy/aws-lua/internal/sandbox"
 	"github.com/tupyy/aws-lua/internal/twt"
 	glua "github.com/yuin/gopher-lua"
 )
@@ -18,18 +27,75 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		os.Exit(runTests(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "repl" {
+		os.Exit(runRepl(os.Args[2:]))
+	}
 
 	// flags
 	luaFile := flag.StringP("filename", "f", "", "lua file")
 	flag.StringVar(&AwsAccessKey, "aws-access-key", "", "AWS access key")
 	flag.StringVar(&AwsSecretkey, "aws-secret-key", "", "AWS secret key")
 	flag.StringVar(&AwsRegion, "aws-region", "", "AWS region")
+	providerName := flag.String("provider", "aws", "provider of the aws module: aws or fake")
+	fixture := flag.String("fake-fixture", "", "json file loaded by the fake provider before the script and saved after it")
+	recordDir := flag.String("record", "", "directory where the aws requests and responses are recorded")
+	replayDir := flag.String("replay", "", "directory of recorded aws requests played back instead of calling aws")
+	endpointURL := flag.String("endpoint-url", "", "endpoint of all the aws services, e.g. http://localhost:4566 for a local emulator")
+	endpoints := flag.StringToString("endpoint", nil, "endpoint of a service: ec2=http://localhost:4566 (repeatable)")
+	insecure := flag.Bool("insecure-skip-tls-verify", false, "do not verify the TLS certificates of the endpoints")
+	varList := flag.StringArray("var", nil, "variable of the script: key=value (repeatable)")
+	varFiles := flag.StringArray("var-file", nil, "json or yaml file of variables (repeatable)")
+	outputFile := flag.String("output-file", "", "file where the outputs are written instead of stdout")
+	outputFormat := flag.String("output-format", "", "format of the outputs: json or yaml (default from the extension of --output-file, else json)")
+	showSensitive := flag.Bool("show-sensitive", false, "write the sensitive outputs instead of masking them")
+	logLevel := flag.String("log-level", "info", "level of the logs written to stderr: debug, info, warn or error")
+	logFormat := flag.String("log-format", "text", "format of the logs: text or json")
+	allow := flag.StringArray("allow", nil, "resource types and verbs allowed to the script: aws_vpc, aws_*:get,list, ec2.Describe*:call (repeatable, all when not set)")
+	sandboxed := flag.Bool("sandbox", false, "run the script in a sandbox: whitelisted libraries, no files out of --sandbox-dir, limits")
+	sandboxLibs := flag.StringSlice("sandbox-libs", sandbox.DefaultLibs, "libraries opened in the sandbox")
+	sandboxDir := flag.String("sandbox-dir", "", "only directory whose files can be accessed in the sandbox")
+	maxInstructions := flag.Int64("max-instructions", 0, "number of lua instructions after which the sandbox stops the script (0 for no limit)")
+	maxMemory := flag.Uint64("max-memory-mb", 0, "heap size in MB above which the sandbox stops the script (0 for no limit)")
+	timeout := flag.Duration("timeout", 0, "wall time after which the sandbox stops the script (0 for no limit)")
 	flag.Parse()
 
-	if flag.NFlag() != 4 {
+	if *luaFile == "" {
 		flag.Usage()
 		os.Exit(0)
 	}
+	if err := setLogger(*logLevel, *logFormat); err != nil {
+		log.Fatal(err)
+	}
+
+	if *outputFormat == "" {
+		*outputFormat = "json"
+		if ext := filepath.Ext(*outputFile); ext == ".yaml" || ext == ".yml" {
+			*outputFormat = "yaml"
+		}
+	}
+	if *outputFormat != "json" && *outputFormat != "yaml" {
+		log.Fatalf("unknown output format %q, expected json or yaml", *outputFormat)
+	}
+
+	vars, err := loadVars(*varFiles, *varList)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	policy, err := sandbox.ParsePolicy(*allow)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !*sandboxed {
+		for _, name := range []string{"sandbox-libs", "sandbox-dir", "max-instructions", "max-memory-mb", "timeout"} {
+			if flag.CommandLine.Changed(name) {
+				log.Fatalf("--%s requires --sandbox", name)
+			}
+		}
+	}
 
 	f, err := os.OpenFile(*luaFile, os.O_RDONLY, 0755)
 	if err != nil {
@@ -37,22 +103,216 @@ func main() {
 	}
 	f.Close()
 
-	L := glua.NewState()
+	var L *glua.LState
+	if *sandboxed {
+		var cancel func()
+		L, cancel, err = sandbox.NewState(sandbox.Options{
+			Libs:            *sandboxLibs,
+			Dir:             *sandboxDir,
+			MaxInstructions: *maxInstructions,
+			MaxMemory:       *maxMemory << 20,
+			Timeout:         *timeout,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer cancel()
+	} else {
+		L = glua.NewState()
+	}
 	defer L.Close()
 
-	awsProvider := aws.New(aws.ClientConfiguration{
-		AccessKey: AwsAccessKey,
-		SecretKey: AwsSecretkey,
-		Region:    AwsRegion,
+	awsProvider, done, err := newProvider(providerOptions{
+		name: *providerName,
+		config: aws.ClientConfiguration{
+			AccessKey:          AwsAccessKey,
+			SecretKey:          AwsSecretkey,
+			Region:             AwsRegion,
+			EndpointURL:        *endpointURL,
+			Endpoints:          *endpoints,
+			InsecureSkipVerify: *insecure,
+		},
+		fixture:     *fixture,
+		saveFixture: true,
+		record:      *recordDir,
+		replay:      *replayDir,
 	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	twtProvider := twt.New("secret")
-
-	L.PreloadModule("aws", lua.NewAwsModule(awsProvider).Loader)
-	L.PreloadModule("twt", lua.NewTwtModule(twtProvider).Loader)
+	awsModule := lua.NewAwsModule(sandbox.NewProvider(awsProvider, policy))
+	L.PreloadModule("aws", awsModule.Loader)
+	preloadModules(L, vars)
 
-	if err := L.DoFile(*luaFile); err != nil {
+	// the fixture is saved even when the script fails, with the resources created until then.
+	err = L.DoFile(*luaFile)
+	doneErr := done()
+	if err != nil {
 		panic(err)
 	}
+	if doneErr != nil {
+		log.Fatal(doneErr)
+	}
+
+	if err := writeOutputs(awsModule.Outputs(), *outputFile, *outputFormat, *showSensitive); err != nil {
+		log.Fatalf("cannot write outputs: %s", err)
+	}
+
+}
+
+// providerOptions select and configure the provider of the aws module.
+type providerOptions struct {
+	// name is aws or fake.
+	name   string
+	config aws.ClientConfiguration
+	// fixture is the file of the resources loaded by the fake provider, when it exists.
+	fixture string
+	// saveFixture saves the resources of the fake provider into the fixture when the run is done.
+	saveFixture bool
+	// record and replay are the directories where the requests to aws are recorded or played back.
+	record string
+	replay string
+}
+
+// newProvider returns the provider of the aws module and the function to call when the run is done.
+// The done function saves the fixture and returns an error when recorded requests were not replayed.
+func newProvider(o providerOptions) (lua.AwsProvider, func() error, error) {
+	if o.record != "" && o.replay != "" {
+		return nil, nil, errors.New("--record and --replay cannot be used together")
+	}
+
+	switch o.name {
+	case "aws":
+		config := o.config
+		if o.replay != "" && config.AccessKey == "" && config.SecretKey == "" {
+			// requests are signed before being played back, the credentials only have to be set.
+			config.AccessKey, config.SecretKey = "replay", "replay"
+		}
+		if config.AccessKey == "" || config.SecretKey == "" || config.Region == "" {
+			return nil, nil, errors.New("the aws provider requires --aws-access-key, --aws-secret-key and --aws-region")
+		}
+		if err := config.Validate(); err != nil {
+			return nil, nil, err
+		}
+
+		done := func() error { return nil }
+		switch {
+		case o.record != "":
+			var transport http.RoundTripper
+			if config.InsecureSkipVerify {
+				t := http.DefaultTransport.(*http.Transport).Clone()
+				t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+				transport = t
+			}
+			recorder, err := cassette.NewRecorder(o.record, transport)
+			if err != nil {
+				return nil, nil, fmt.Errorf("cannot record: %w", err)
+			}
+			config.HTTPClient = &http.Client{Transport: recorder}
+		case o.replay != "":
+			player, err := cassette.NewPlayer(o.replay)
+			if err != nil {
+				return nil, nil, fmt.Errorf("cannot replay: %w", err)
+			}
+			config.HTTPClient = &http.Client{Transport: player}
+			done = func() error {
+				if n := player.Remaining(); n > 0 {
+					return fmt.Errorf("%d recorded requests were not replayed", n)
+				}
+				return nil
+			}
+		}
+		return aws.New(config), done, nil
 
+	case "fake":
+		if o.record != "" || o.replay != "" {
+			return nil, nil, errors.New("--record and --replay require the aws provider")
+		}
+		p := fake.New(o.config.Region)
+		if o.fixture != "" {
+			if err := p.Load(o.fixture); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, nil, fmt.Errorf("cannot load fixture: %w", err)
+			}
+		}
+		done := func() error {
+			if o.fixture == "" || !o.saveFixture {
+				return nil
+			}
+			if err := p.Save(o.fixture); err != nil {
+				return fmt.Errorf("cannot save fixture: %w", err)
+			}
+			return nil
+		}
+		return p, done, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown provider %q", o.name)
+}
+
+// preloadModules preloads the modules other than aws and sets the variables of the scripts.
+func preloadModules(L *glua.LState, vars lua.Object) {
+	L.PreloadModule("twt", lua.NewTwtModule(twt.New("secret")).Loader)
+	L.PreloadModule("json", lua.NewJsonModule().Loader)
+	L.PreloadModule("yaml", lua.NewYamlModule().Loader)
+	L.PreloadModule("cidr", lua.NewCidrModule().Loader)
+	L.PreloadModule("log", lua.NewLogModule(logger.Default()).Loader)
+	lua.NewVars(vars).Register(L)
+}
+
+// loadVars returns the variables of the files, in their order, overridden by the key=value variables.
+func loadVars(files, vars []string) (lua.Object, error) {
+	values := lua.Object{}
+	for _, file := range files {
+		o, err := lua.LoadVarFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range o {
+			values[k] = v
+		}
+	}
+	for _, v := range vars {
+		key, value, err := lua.ParseVar(v)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// writeOutputs writes the outputs into the file, or to stdout when the script has set some.
+func writeOutputs(outputs *lua.Outputs, file, format string, showSensitive bool) error {
+	if file == "" {
+		if outputs.Len() == 0 {
+			return nil
+		}
+		return outputs.Write(os.Stdout, format, showSensitive)
+	}
+
+	// the file may hold sensitive values.
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := outputs.Write(f, format, showSensitive); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// setLogger sets the logger shared by the providers and the lua modules.
+func setLogger(level, format string) error {
+	l, err := logger.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	lg, err := logger.New(os.Stderr, l, format)
+	if err != nil {
+		return err
+	}
+	logger.SetDefault(lg)
+	return nil
 }
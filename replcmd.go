@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	flag "github.com/spf13/pflag"
+	"github.com/tupyy/aws-lua/internal/aws"
+	"github.com/tupyy/aws-lua/internal/lua"
+	"github.com/tupyy/aws-lua/internal/repl"
+	glua "github.com/yuin/gopher-lua"
+)
+
+// replModules are the modules set as globals in the repl.
+var replModules = []string{"aws", "twt", "json", "yaml", "cidr", "log"}
+
+// runRepl implements "aws-lua repl" and returns the exit code.
+// The modules are required into globals of the same name, so that aws.list("aws_vpc") works right away.
+func runRepl(args []string) int {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	accessKey := flags.String("aws-access-key", "", "AWS access key")
+	secretKey := flags.String("aws-secret-key", "", "AWS secret key")
+	region := flags.String("aws-region", "", "AWS region")
+	providerName := flags.String("provider", "aws", "provider of the aws module: aws or fake")
+	fixture := flags.String("fake-fixture", "", "json file loaded by the fake provider when the repl starts and saved when it stops")
+	endpointURL := flags.String("endpoint-url", "", "endpoint of all the aws services, e.g. http://localhost:4566 for a local emulator")
+	endpoints := flags.StringToString("endpoint", nil, "endpoint of a service: ec2=http://localhost:4566 (repeatable)")
+	insecure := flags.Bool("insecure-skip-tls-verify", false, "do not verify the TLS certificates of the endpoints")
+	varList := flags.StringArray("var", nil, "variable of the scripts: key=value (repeatable)")
+	varFiles := flags.StringArray("var-file", nil, "json or yaml file of variables (repeatable)")
+	history := flags.String("history", defaultHistoryFile(), "file of the history of the repl, not saved when empty")
+	logLevel := flags.String("log-level", "info", "level of the logs written to stderr: debug, info, warn or error")
+	logFormat := flags.String("log-format", "text", "format of the logs: text or json")
+	flags.Parse(args)
+
+	if err := setLogger(*logLevel, *logFormat); err != nil {
+		log.Fatal(err)
+	}
+	vars, err := loadVars(*varFiles, *varList)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	awsProvider, done, err := newProvider(providerOptions{
+		name: *providerName,
+		config: aws.ClientConfiguration{
+			AccessKey:          *accessKey,
+			SecretKey:          *secretKey,
+			Region:             *region,
+			EndpointURL:        *endpointURL,
+			Endpoints:          *endpoints,
+			InsecureSkipVerify: *insecure,
+		},
+		fixture:     *fixture,
+		saveFixture: true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := done(); err != nil {
+			log.Print(err)
+		}
+	}()
+
+	L := glua.NewState()
+	defer L.Close()
+	L.PreloadModule("aws", lua.NewAwsModule(awsProvider).Loader)
+	preloadModules(L, vars)
+	for _, name := range replModules {
+		if err := L.DoString(fmt.Sprintf("%s = require(%q)", name, name)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	fmt.Printf("aws-lua repl (%s provider). The modules %v are loaded. Ctrl-D to exit.\n", *providerName, replModules)
+	r := repl.Repl{
+		L:           L,
+		In:          os.Stdin,
+		Out:         os.Stdout,
+		HistoryFile: *history,
+		Types: func() []string {
+			names := []string{}
+			for _, t := range awsProvider.Types() {
+				names = append(names, t.GetString("name"))
+			}
+			return names
+		},
+	}
+	if err := r.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws_lua_history")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"github.com/tupyy/aws-lua/internal/aws"
+	"github.com/tupyy/aws-lua/internal/lua"
+	"github.com/tupyy/aws-lua/internal/luatest"
+	glua "github.com/yuin/gopher-lua"
+)
+
+// runTests implements "aws-lua test [dir]" and returns the exit code.
+// Tests run with the fake provider, or replay the requests recorded for each test with --replay.
+// The cassettes of a test are in <dir>/<file without _test.lua>/<test name>.
+func runTests(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	region := flags.String("aws-region", "us-east-1", "AWS region")
+	accessKey := flags.String("aws-access-key", "", "AWS access key, required by --record")
+	secretKey := flags.String("aws-secret-key", "", "AWS secret key, required by --record")
+	fixture := flags.String("fake-fixture", "", "json file loaded by the fake provider before each test")
+	recordDir := flags.String("record", "", "directory where the aws requests of each test are recorded")
+	replayDir := flags.String("replay", "", "directory of the aws requests recorded for each test")
+	junit := flags.String("junit", "", "file where a JUnit XML report is written")
+	varList := flags.StringArray("var", nil, "variable of the scripts: key=value (repeatable)")
+	varFiles := flags.StringArray("var-file", nil, "json or yaml file of variables (repeatable)")
+	logLevel := flags.String("log-level", "warn", "level of the logs written to stderr: debug, info, warn or error")
+	logFormat := flags.String("log-format", "text", "format of the logs: text or json")
+	flags.Parse(args)
+
+	if err := setLogger(*logLevel, *logFormat); err != nil {
+		log.Fatal(err)
+	}
+
+	if *recordDir != "" && *replayDir != "" {
+		log.Fatal("--record and --replay cannot be used together")
+	}
+
+	vars, err := loadVars(*varFiles, *varList)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	files, err := luatest.Discover(dir)
+	if err != nil {
+		log.Fatalf("cannot find tests: %s", err)
+	}
+	if len(files) == 0 {
+		fmt.Printf("no test files in %s\n", dir)
+		return 0
+	}
+
+	cassettes := func(root, file, test string) string {
+		return filepath.Join(root, strings.TrimSuffix(filepath.Base(file), "_test.lua"), test)
+	}
+
+	runner := luatest.Runner{
+		Out: os.Stdout,
+		Preload: func(L *glua.LState) {
+			preloadModules(L, vars)
+		},
+		Provider: func(file, test string) (lua.AwsProvider, func() error, error) {
+			o := providerOptions{
+				name:    "fake",
+				config:  aws.ClientConfiguration{AccessKey: *accessKey, SecretKey: *secretKey, Region: *region},
+				fixture: *fixture,
+			}
+			switch {
+			case *recordDir != "":
+				o.name, o.record = "aws", cassettes(*recordDir, file, test)
+			case *replayDir != "":
+				o.name, o.replay = "aws", cassettes(*replayDir, file, test)
+			}
+			return newProvider(o)
+		},
+	}
+	results := runner.Run(files)
+
+	if *junit != "" {
+		if err := writeJUnit(*junit, results); err != nil {
+			log.Printf("cannot write JUnit report: %s", err)
+			return 1
+		}
+	}
+
+	for _, r := range results {
+		if r.Failure != "" {
+			return 1
+		}
+	}
+	return 0
+}
+
+func writeJUnit(path string, results []luatest.Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := luatest.WriteJUnit(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}